@@ -0,0 +1,42 @@
+package goflat
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// asciiSpellings lists letters without a canonical decomposition and their ASCII spelling.
+var asciiSpellings = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'đ': "d", 'Đ': "D", 'ł': "l", 'Ł': "L", 'þ': "th", 'Þ': "Th", 'ð': "d", 'Ð': "D",
+	'ı': "i", 'ħ': "h", 'Ħ': "H",
+}
+
+// transliterate is a helper function that rewrites seg to ASCII per Options.ASCIIOnlyKeys.
+func transliterate(seg string) string {
+	ascii := true
+	for i := 0; i < len(seg); i++ {
+		if seg[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii && seg != "" {
+		return seg
+	}
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(seg) {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		} else if spelled, ok := asciiSpellings[r]; ok {
+			b.WriteString(spelled)
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
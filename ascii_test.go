@@ -0,0 +1,64 @@
+package goflat_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestASCIIOnlyKeys(t *testing.T) {
+	// Test case 1: Accents are removed, some letters spelled out and other scripts dropped
+	data := []byte(`{"café": {"crème brûlée": 1}, "straße": 2, "日本": 3, "名前x": 4, "tags": ["é"]}`)
+	options := goflat.DefaultOptions()
+	options.ASCIIOnlyKeys = true
+	expected := map[string]interface{}{
+		"cafe.creme brulee": float64(1),
+		"strasse":           float64(2),
+		"_":                 float64(3),
+		"x":                 float64(4),
+		"tags.0":            "é",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Keys that only differ in accents collide
+	data = []byte(`{"resume": 1, "résumé": 2}`)
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrKeyCollision) {
+		t.Errorf("Expected ErrKeyCollision, got %v", err)
+	}
+
+	// Test case 3: Colliding keys are numbered with ASCIISuffixCollisions
+	options.ASCIISuffixCollisions = true
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"resume": float64(1), "resume_2": float64(2)}) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 4: Escaped delimiters are kept, in rewritten and in unchanged keys
+	options = goflat.DefaultOptions()
+	options.ASCIIOnlyKeys = true
+	options.EscapeChar = '\\'
+	data = []byte(`{"v1.2": {"café.menu": 1}, "a.b": 2}`)
+	expected = map[string]interface{}{
+		`v1\.2.cafe\.menu`: float64(1),
+		`a\.b`:             float64(2),
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Result %v does not match expected result", result)
+	}
+}
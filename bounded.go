@@ -0,0 +1,46 @@
+package goflat
+
+import "context"
+
+// FlattenJSONBounded flattens a JSON object like FlattenJSON while enforcing every guard meant for
+// untrusted input at once, returning the error of the first one that trips:
+//
+//   - nesting deeper than options.MaxDepth fails with ErrDepthExceeded instead of storing the
+//     remaining subtree whole;
+//   - more than options.MaxKeys flattened keys fail with ErrTooManyKeys;
+//   - an object with more than options.MaxBreadth members fails with ErrBreadthExceeded;
+//   - a done ctx stops the walk with ctx.Err(), such as context.DeadlineExceeded.
+//
+// MaxKeys and MaxBreadth are disabled when they are not positive, and MaxDepth when it is negative
+// (-1 as in DefaultOptions). MaxDepth 0 is a limit like any other: it allows scalar top-level
+// members only, so any top-level object or array fails with ErrDepthExceeded.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//	defer cancel()
+//	options := DefaultOptions()
+//	options.MaxDepth = 1
+//	_, err := FlattenJSONBounded(ctx, []byte(`{"a": {"b": {"c": 1}}}`), options)
+//	fmt.Println(err)
+//
+// Output:
+//
+//	goflat: maximum depth exceeded: "a.b"
+func FlattenJSONBounded(ctx context.Context, data []byte, options Options) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result, err := jsonDecoder(options)(data)
+	if err != nil {
+		return nil, err
+	}
+	f := newFlattener(options)
+	f.ctx = ctx
+	f.bounded = true
+	f.run(result)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.flattened, nil
+}
@@ -0,0 +1,89 @@
+package goflat_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFlattenJSONBounded(t *testing.T) {
+	data := []byte(`{"name": "John", "address": {"street": "123 Main St", "city": "New York"}}`)
+
+	// Test case 1: A document within every guard is flattened
+	options := goflat.DefaultOptions()
+	options.MaxDepth = 1
+	options.MaxKeys = 3
+	options.MaxBreadth = 2
+	expected := map[string]interface{}{
+		"name":           "John",
+		addressStreetKey: addressStreet,
+		addressCityKey:   addressCity,
+	}
+	result, err := goflat.FlattenJSONBounded(context.Background(), data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Nesting deeper than MaxDepth
+	options = goflat.DefaultOptions()
+	options.MaxDepth = 0
+	_, err = goflat.FlattenJSONBounded(context.Background(), data, options)
+	if !errors.Is(err, goflat.ErrDepthExceeded) {
+		t.Errorf("Expected ErrDepthExceeded, got %v", err)
+	}
+
+	// Test case 3: More keys than MaxKeys
+	options = goflat.DefaultOptions()
+	options.MaxKeys = 2
+	_, err = goflat.FlattenJSONBounded(context.Background(), data, options)
+	if !errors.Is(err, goflat.ErrTooManyKeys) {
+		t.Errorf("Expected ErrTooManyKeys, got %v", err)
+	}
+
+	// Test case 4: A wider object than MaxBreadth
+	options = goflat.DefaultOptions()
+	options.MaxBreadth = 1
+	_, err = goflat.FlattenJSONBounded(context.Background(), data, options)
+	if !errors.Is(err, goflat.ErrBreadthExceeded) {
+		t.Errorf("Expected ErrBreadthExceeded, got %v", err)
+	}
+
+	// Test case 5: An expired deadline
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = goflat.FlattenJSONBounded(ctx, data, goflat.DefaultOptions())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// Test case 6: A deadline that passes during the walk
+	ctx, cancel = context.WithCancel(context.Background())
+	options = goflat.DefaultOptions()
+	options.LeafVisitor = func(path []string, value interface{}) {
+		cancel()
+	}
+	_, err = goflat.FlattenJSONBounded(ctx, data, options)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// Test case 7: Zero limits disable MaxKeys and MaxBreadth, while MaxDepth 0 allows scalars only
+	options = goflat.DefaultOptions()
+	options.MaxKeys = 0
+	options.MaxBreadth = 0
+	if result, err = goflat.FlattenJSONBounded(context.Background(), data, options); err != nil || len(result) != 3 {
+		t.Errorf("Expected zero limits to be disabled, got %v (%v)", result, err)
+	}
+	options.MaxDepth = 0
+	result, err = goflat.FlattenJSONBounded(context.Background(), []byte(`{"name": "John", "age": 30}`), options)
+	if err != nil || len(result) != 2 {
+		t.Errorf("Expected scalar members within MaxDepth 0, got %v (%v)", result, err)
+	}
+}
@@ -0,0 +1,259 @@
+package goflat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// FlattenJSONWithPositions flattens a JSON object like FlattenJSON and also returns the byte
+// offset in data at which the value of each flattened key starts. The offsets can be used to
+// report errors against the original document.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "address": {"city": "New York"}}`)
+//	flattened, positions, err := FlattenJSONWithPositions(data, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(flattened, positions)
+//
+// Output:
+//
+//	map[address.city:New York name:John] map[address.city:37 name:9]
+func FlattenJSONWithPositions(data []byte, options Options) (map[string]interface{}, map[string]int, error) {
+	d := newTokenDecoder(data)
+	d.positions = true
+	if options.PreserveNumberText {
+		d.dec.UseNumber()
+	}
+	value, err := d.decodeObject()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := newFlattener(options)
+	f.positions = make(map[string]int)
+	f.run(value)
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+
+	// Offsets are recorded for containers on the way down; keep only those of leaves.
+	positions := make(map[string]int, len(f.flattened))
+	for key := range f.flattened {
+		if offset, ok := f.positions[key]; ok {
+			positions[key] = offset
+		}
+	}
+	return f.flattened, positions, nil
+}
+
+// FlattenJSONOrdered flattens a JSON object like FlattenJSON and, when options.TrackOrder is set,
+// also returns its flattened keys in the order their values appear in data. Keys that do not come
+// from a source value, such as those added by ExpandLeaf, Derive or TagArrays, follow in sorted order.
+// Pass the order to UnflattenJSONOrdered to reproduce the original member order.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "address": {"state": "NY", "city": "New York"}}`)
+//	options := DefaultOptions()
+//	options.TrackOrder = true
+//	_, order, err := FlattenJSONOrdered(data, options)
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(order)
+//
+// Output:
+//
+//	[name address.state address.city]
+func FlattenJSONOrdered(data []byte, options Options) (map[string]interface{}, []string, error) {
+	if !options.TrackOrder {
+		flattened, err := FlattenJSON(data, options)
+		return flattened, nil, err
+	}
+	flattened, positions, err := FlattenJSONWithPositions(data, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	order := sortedKeys(flattened)
+	sort.SliceStable(order, func(i, j int) bool {
+		pi, okI := positions[order[i]]
+		pj, okJ := positions[order[j]]
+		if okI != okJ {
+			return okI
+		}
+		return okI && pi < pj
+	})
+	return flattened, order, nil
+}
+
+// FlattenJSONStrict flattens a JSON object like FlattenJSON but rejects any non-whitespace data
+// after the top-level value with ErrTrailingData, which json.Unmarshal would report less clearly.
+// This catches concatenated documents such as `{"a": 1}{"b": 2}`. Comments are never valid JSON
+// and are rejected as syntax errors.
+func FlattenJSONStrict(data []byte, options Options) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Has no effect when decoding into a map, but keeps the decoder strict if the target changes.
+	dec.DisallowUnknownFields()
+	if options.PreserveNumberText {
+		dec.UseNumber()
+	}
+	var result map[string]interface{}
+	if err := dec.Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data[dec.InputOffset():])) > 0 {
+		return nil, fmt.Errorf("%w at offset %d", ErrTrailingData, dec.InputOffset())
+	}
+	return flattenMap(result, options)
+}
+
+// decodeNumberText is a helper function that decodes a JSON object through the token decoder,
+// keeping every number as a json.Number with its source text.
+func decodeNumberText(data []byte) (map[string]interface{}, error) {
+	d := newTokenDecoder(data)
+	d.dec.UseNumber()
+	return d.decodeObject()
+}
+
+// decodeObject is a helper function that decodes a value that must be a JSON object and
+// must be the only value in the data.
+func (d *tokenDecoder) decodeObject() (map[string]interface{}, error) {
+	m, err := d.decodeFirstObject()
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(d.data[d.dec.InputOffset():])) > 0 {
+		return nil, fmt.Errorf("%w at offset %d", ErrTrailingData, d.dec.InputOffset())
+	}
+	return m, nil
+}
+
+// positioned wraps a decoded value together with the offset at which it starts in the source.
+type positioned struct {
+	value  interface{}
+	offset int
+}
+
+// stripPositions is a helper function that recursively removes positioned wrappers.
+func stripPositions(value interface{}) interface{} {
+	switch v := value.(type) {
+	case positioned:
+		return stripPositions(v.value)
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = stripPositions(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = stripPositions(val)
+		}
+	}
+	return value
+}
+
+// tokenDecoder decodes JSON through the streaming token API of json.Decoder.
+type tokenDecoder struct {
+	data      []byte
+	dec       *json.Decoder
+	positions bool // Whether decoded values are wrapped in positioned
+}
+
+// newTokenDecoder is a helper function that creates a tokenDecoder reading from data.
+func newTokenDecoder(data []byte) *tokenDecoder {
+	return &tokenDecoder{data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+}
+
+// decodeFirstObject is a helper function that decodes a value that must be a JSON object.
+func (d *tokenDecoder) decodeFirstObject() (map[string]interface{}, error) {
+	value, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := value.(positioned); ok {
+		value = p.value
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, ErrNotObject
+	}
+	return m, nil
+}
+
+// decode is a helper function that decodes the next JSON value.
+func (d *tokenDecoder) decode() (interface{}, error) {
+	offset := d.offset()
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			m := make(map[string]interface{})
+			for d.dec.More() {
+				keyTok, err := d.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyTok.(string)
+				if !ok {
+					return nil, fmt.Errorf("goflat: unexpected token %v, expected object key", keyTok)
+				}
+				val, err := d.decode()
+				if err != nil {
+					return nil, err
+				}
+				m[key] = val
+			}
+			value = m
+		case '[':
+			arr := make([]interface{}, 0)
+			for d.dec.More() {
+				val, err := d.decode()
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, val)
+			}
+			value = arr
+		default:
+			return nil, fmt.Errorf("goflat: unexpected delimiter %v", t)
+		}
+		// Consume the closing delimiter.
+		if _, err := d.dec.Token(); err != nil {
+			return nil, err
+		}
+	default:
+		value = t
+	}
+
+	if d.positions {
+		return positioned{value: value, offset: offset}, nil
+	}
+	return value, nil
+}
+
+// offset is a helper function that returns the offset at which the next value starts,
+// skipping the whitespace and separators json.Decoder has not consumed yet.
+func (d *tokenDecoder) offset() int {
+	offset := int(d.dec.InputOffset())
+	for offset < len(d.data) {
+		switch d.data[offset] {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
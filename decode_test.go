@@ -0,0 +1,159 @@
+package goflat_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFlattenJSONWithPositions(t *testing.T) {
+	// Test case 1: Offsets point at the start of each leaf value
+	data := []byte(`{"name": "John",
+  "address": {"city": "New York"},
+  "hobbies": ["reading", 42]}`)
+	options := goflat.DefaultOptions()
+	flattened, positions, err := goflat.FlattenJSONWithPositions(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected := map[string]interface{}{
+		"name":         "John",
+		addressCityKey: addressCity,
+		hobbies0Key:    hobbies0,
+		hobbies1Key:    float64(42),
+	}
+	if !reflect.DeepEqual(flattened, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+	expectedPositions := map[string]int{
+		"name":         9,
+		addressCityKey: 39,
+		hobbies0Key:    66,
+		hobbies1Key:    77,
+	}
+	if !reflect.DeepEqual(positions, expectedPositions) {
+		t.Errorf("Positions do not match expected result: %v", positions)
+	}
+
+	// Test case 2: A leaf kept whole by MaxDepth is returned without position wrappers
+	options.MaxDepth = 0
+	flattened, positions, err = goflat.FlattenJSONWithPositions(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(flattened["address"], map[string]interface{}{"city": addressCity}) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+	if positions["address"] != 30 {
+		t.Errorf("Unexpected position for address: %d", positions["address"])
+	}
+
+	// Test case 3: A top-level value that is not an object is rejected
+	_, _, err = goflat.FlattenJSONWithPositions([]byte(`[1, 2]`), options)
+	if err != goflat.ErrNotObject {
+		t.Errorf("Expected ErrNotObject, got %v", err)
+	}
+}
+
+func TestFlattenJSONStrict(t *testing.T) {
+	// Test case 1: A valid document with surrounding whitespace
+	data := []byte("  {\"name\": \"John\", \"address\": {\"city\": \"New York\"}}\n")
+	options := goflat.DefaultOptions()
+	expected := map[string]interface{}{
+		"name":         "John",
+		addressCityKey: addressCity,
+	}
+	result, err := goflat.FlattenJSONStrict(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Trailing garbage after the document
+	_, err = goflat.FlattenJSONStrict([]byte(`{"name": "John"} garbage`), options)
+	if !errors.Is(err, goflat.ErrTrailingData) {
+		t.Errorf("Expected ErrTrailingData, got %v", err)
+	}
+
+	// Test case 3: Concatenated documents
+	_, err = goflat.FlattenJSONStrict([]byte(`{"a": 1}{"b": 2}`), options)
+	if !errors.Is(err, goflat.ErrTrailingData) {
+		t.Errorf("Expected ErrTrailingData, got %v", err)
+	}
+
+	// Test case 4: Comments are rejected
+	_, err = goflat.FlattenJSONStrict([]byte(`{"a": 1 // comment
+}`), options)
+	if err == nil {
+		t.Errorf("Expected error for a document with comments")
+	}
+
+	// Test case 5: PreserveNumberText keeps numbers as json.Number like FlattenJSON
+	options.PreserveNumberText = true
+	data = []byte(`{"id": 12345678901234567890, "price": 9.50}`)
+	expected, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Fatalf(errorFlatteningJSON, err)
+	}
+	result, err = goflat.FlattenJSONStrict(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) || result["price"] != json.Number("9.50") {
+		t.Errorf("Strict result %v does not match %v", result, expected)
+	}
+}
+
+func TestPreserveNumberText(t *testing.T) {
+	// Test case 1: Numbers keep their exact text through a round trip
+	data := []byte(`{"price":{"amount":1.0,"scale":1e3},"big":100000000000000000,"list":[0.10,-2E-3]}`)
+	options := goflat.DefaultOptions()
+	options.PreserveNumberText = true
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected := map[string]interface{}{
+		"price.amount": json.Number("1.0"),
+		"price.scale":  json.Number("1e3"),
+		"big":          json.Number("100000000000000000"),
+		"list.0":       json.Number("0.10"),
+		"list.1":       json.Number("-2E-3"),
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	pairs := goflat.OrderedFlatInput{}
+	for _, key := range []string{"price.amount", "price.scale", "big", "list.0", "list.1"} {
+		pairs = append(pairs, goflat.Pair{Key: key, Value: result[key]})
+	}
+	encoded, err := goflat.UnflattenOrderedInput(pairs, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if string(encoded) != string(data) {
+		t.Errorf("Round trip changed the document: %s", encoded)
+	}
+
+	// Test case 2: Trailing data is rejected like json.Unmarshal does
+	_, err = goflat.FlattenJSON([]byte(`{"a": 1} 2`), options)
+	if !errors.Is(err, goflat.ErrTrailingData) {
+		t.Errorf("Expected ErrTrailingData, got %v", err)
+	}
+
+	// Test case 3: Without the option numbers decode as float64
+	options.PreserveNumberText = false
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if result["price.amount"] != float64(1) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
@@ -0,0 +1,48 @@
+package goflat
+
+import "errors"
+
+var (
+	// ErrNotObject is returned when the top-level JSON value to flatten is not an object.
+	ErrNotObject = errors.New("goflat: top-level JSON value is not an object")
+	// ErrKeyConflict is returned when a flattened key needs a leaf and a nested value at the same path.
+	ErrKeyConflict = errors.New("goflat: key conflicts with an existing value")
+	// ErrTrailingData is returned by FlattenJSONStrict when data continues after the top-level value.
+	ErrTrailingData = errors.New("goflat: trailing data after top-level value")
+	// ErrKeyNotAllowed is returned by ValidateKeys for keys that match none of the allowed patterns.
+	ErrKeyNotAllowed = errors.New("goflat: key not allowed")
+	// ErrIndexTooWide is returned when an array index has more digits than Options.PadIndexWidth
+	// and Options.PadIndexStrict is set, or when an array has more wide indices than there are
+	// Options.MaxIndexDigits segments.
+	ErrIndexTooWide = errors.New("goflat: array index wider than padding width")
+	// ErrUnsupportedKind is returned by FlattenMapStrict for leaf values JSON cannot represent.
+	ErrUnsupportedKind = errors.New("goflat: unsupported value kind")
+	// ErrPrefixPathMissing is returned when Options.PrefixFromPath is required but absent.
+	ErrPrefixPathMissing = errors.New("goflat: prefix path missing")
+	// ErrBreadthExceeded is returned when an object has more members than Options.MaxBreadth.
+	ErrBreadthExceeded = errors.New("goflat: maximum breadth exceeded")
+	// ErrKeyCollision is returned when rewriting keys makes two distinct keys identical.
+	ErrKeyCollision = errors.New("goflat: keys collide")
+	// ErrTooManyKeys is returned when a document produces more keys than Options.MaxKeys.
+	ErrTooManyKeys = errors.New("goflat: maximum number of keys exceeded")
+	// ErrDepthExceeded is returned by FlattenJSONBounded when nesting goes deeper than Options.MaxDepth.
+	ErrDepthExceeded = errors.New("goflat: maximum depth exceeded")
+	// ErrNonFiniteFloat is returned for NaN and infinite floats when Options.NonFiniteFloatPolicy
+	// is NonFiniteError.
+	ErrNonFiniteFloat = errors.New("goflat: non-finite float")
+	// ErrInvalidJSONBytes is returned for byte leaves that are not valid JSON when
+	// Options.ExpandJSONBytesStrict is set.
+	ErrInvalidJSONBytes = errors.New("goflat: invalid JSON bytes")
+	// ErrUnwrapMismatch is returned when a document does not match Options.UnwrapKeys and
+	// Options.UnwrapKeysRequired is set.
+	ErrUnwrapMismatch = errors.New("goflat: document does not match wrapper keys")
+	// ErrOutputTooLarge is returned by FlattenJSONToBytesLimited when the output exceeds its limit.
+	ErrOutputTooLarge = errors.New("goflat: output too large")
+	// ErrKindMismatch is returned by FlattenValidate for leaves whose kind differs from the schema.
+	ErrKindMismatch = errors.New("goflat: value kind does not match schema")
+	// ErrInvalidFieldName is returned for map keys MongoDB does not accept when Options.MongoKeys
+	// is set.
+	ErrInvalidFieldName = errors.New("goflat: invalid field name")
+	// ErrLengthMismatch is returned by UnflattenSorted when keys and values differ in length.
+	ErrLengthMismatch = errors.New("goflat: keys and values differ in length")
+)
@@ -0,0 +1,447 @@
+package goflat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FlattenColumns flattens each record and pivots the results into columns keyed by flattened path.
+// Every column has one entry per record, aligned by record index, with nil where a record lacks the key.
+// The first record that fails to flatten stops the call with its error.
+//
+// Example:
+//
+//	records := []map[string]interface{}{
+//		{"name": "John", "address": map[string]interface{}{"city": "New York"}},
+//		{"name": "Jane", "age": 25},
+//	}
+//	columns, err := FlattenColumns(records, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(columns)
+//
+// Output:
+//
+//	map[address.city:[New York <nil>] age:[<nil> 25] name:[John Jane]]
+func FlattenColumns(records []map[string]interface{}, options Options) (map[string][]interface{}, error) {
+	columns := make(map[string][]interface{})
+	for i, record := range records {
+		flattened, err := flattenMap(record, options)
+		if err != nil {
+			return nil, fmt.Errorf("goflat: record %d: %w", i, err)
+		}
+		for key, value := range flattened {
+			column, ok := columns[key]
+			if !ok {
+				column = make([]interface{}, len(records))
+				columns[key] = column
+			}
+			column[i] = value
+		}
+	}
+	return columns, nil
+}
+
+// FlattenToNDJSON flattens a JSON object and writes every leaf to w as its own NDJSON line of the
+// form {"key":"a.b","value":1}. Leaves are streamed through WalkLeaves, in sorted key order when
+// options.SortKeys is set.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "address": {"city": "New York"}}`)
+//	options := DefaultOptions()
+//	options.SortKeys = true
+//	err := FlattenToNDJSON(data, os.Stdout, options)
+//
+// Output:
+//
+//	{"key":"address.city","value":"New York"}
+//	{"key":"name","value":"John"}
+func FlattenToNDJSON(data []byte, w io.Writer, options Options) error {
+	decoded, err := jsonDecoder(options)(data)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	return WalkLeaves(decoded, options, func(key string, value interface{}) error {
+		return enc.Encode(ndjsonRecord{Key: key, Value: value})
+	})
+}
+
+// ndjsonRecord is a single line written by FlattenToNDJSON.
+type ndjsonRecord struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// FlattenJSONToBytes flattens a JSON object and returns the flattened map encoded as JSON, with
+// keys in sorted order, or in options.KeySort order when it is set.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "address": {"city": "New York"}}`)
+//	out, err := FlattenJSONToBytes(data, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(string(out))
+//
+// Output:
+//
+//	{"address.city":"New York","name":"John"}
+func FlattenJSONToBytes(data []byte, options Options) ([]byte, error) {
+	flattened, err := FlattenJSON(data, options)
+	if err != nil {
+		return nil, err
+	}
+	if options.KeySort == nil {
+		return json.Marshal(flattened)
+	}
+	return encodeObject(flattened, keyOrder(flattened, options), -1, nil)
+}
+
+// FlattenJSONToBytesLimited flattens a JSON object like FlattenJSONToBytes but fails with
+// ErrOutputTooLarge when the encoded result would exceed limit bytes. The keys alone are checked
+// against the limit before any value is encoded, and encoding stops as soon as the limit is passed,
+// so oversized documents fail without being serialized in full.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "address": {"city": "New York"}}`)
+//	_, err := FlattenJSONToBytesLimited(data, 16, DefaultOptions())
+//	fmt.Println(err)
+//
+// Output:
+//
+//	goflat: output too large: more than 16 bytes
+func FlattenJSONToBytesLimited(data []byte, limit int, options Options) ([]byte, error) {
+	flattened, err := FlattenJSON(data, options)
+	if err != nil {
+		return nil, err
+	}
+	tooLarge := fmt.Errorf("%w: more than %d bytes", ErrOutputTooLarge, limit)
+
+	// Every entry needs at least its key, two quotes, a colon, a one-byte value and a separator.
+	estimate := 2
+	for key := range flattened {
+		estimate += len(key) + 5
+	}
+	if estimate-1 > limit && len(flattened) > 0 {
+		return nil, tooLarge
+	}
+
+	return encodeObject(flattened, keyOrder(flattened, options), limit, tooLarge)
+}
+
+// encodeObject is a helper function that encodes flattened as a JSON object with its members in
+// the order of keys. With a limit that is not negative, it fails with tooLarge as soon as the
+// output would pass limit bytes.
+func encodeObject(flattened map[string]interface{}, keys []string, limit int, tooLarge error) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(flattened[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+		if limit >= 0 && buf.Len()+1 > limit {
+			return nil, tooLarge
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// FlattenToYAML flattens a JSON object and writes it to w as a flat YAML mapping, one
+// "key: value" line per leaf. Values are written as JSON, which YAML reads as flow scalars and
+// collections. Keys are written bare when they only hold letters, digits and "_", ".", "/" or "-",
+// and quoted otherwise; set options.QuoteKeys to quote every key, so that keys such as true or 42
+// stay strings. Lines are in sorted key order when options.SortKeys is set.
+//
+// Example:
+//
+//	data := []byte(`{"true": 1, "address": {"city": "New York"}}`)
+//	options := DefaultOptions()
+//	options.SortKeys = true
+//	options.QuoteKeys = true
+//	err := FlattenToYAML(data, os.Stdout, options)
+//
+// Output:
+//
+//	"address.city": "New York"
+//	"true": 1
+func FlattenToYAML(data []byte, w io.Writer, options Options) error {
+	decoded, err := jsonDecoder(options)(data)
+	if err != nil {
+		return err
+	}
+	return WalkLeaves(decoded, options, func(key string, value interface{}) error {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s: %s\n", yamlKey(key, options.QuoteKeys), encoded)
+		return err
+	})
+}
+
+// yamlKey is a helper function that writes key as a YAML mapping key, quoting it when quote is set
+// or when it holds characters that are not safe in a plain scalar.
+func yamlKey(key string, quote bool) string {
+	if quote || key == "" || key[0] == '-' || strings.IndexFunc(key, isUnsafeYAMLRune) >= 0 {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+// isUnsafeYAMLRune is a helper function that reports whether r needs quoting in a plain YAML key.
+func isUnsafeYAMLRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_./-", r))
+}
+
+// FlattenToPrometheus flattens a JSON object and writes its numeric leaves as Prometheus text
+// exposition lines such as "prefix_address_geo_lat 40.7", one per leaf in sorted order, ready for
+// a textfile collector. Names join namePrefix and the key with "_", with every character that is
+// not valid in a metric name, delimiters and indices included, replaced by "_". Leaves that are
+// not numbers, booleans and strings included, are skipped.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "stats": {"visits": 42, "ratio": 0.5}}`)
+//	out, err := FlattenToPrometheus(data, "user", DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Print(string(out))
+//
+// Output:
+//
+//	user_stats_ratio 0.5
+//	user_stats_visits 42
+func FlattenToPrometheus(data []byte, namePrefix string, options Options) ([]byte, error) {
+	flattened, err := FlattenJSON(data, options)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, key := range keyOrder(flattened, options) {
+		value, ok := numericValue(flattened[key])
+		if !ok {
+			continue
+		}
+		b.WriteString(metricName(namePrefix, key))
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+// metricName is a helper function that builds a Prometheus metric name from prefix and key.
+func metricName(prefix, key string) string {
+	name := key
+	if prefix != "" {
+		name = prefix + "_" + key
+	}
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || r == ':' || (i > 0 && r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// numericValue is a helper function that converts a numeric leaf to a float64.
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Row is a single flattened leaf as produced by FlattenToRows, shaped for key/value tables.
+// Kind is the type tag of Value, such as TypeString or TypeInteger.
+type Row struct {
+	Key   string
+	Value interface{}
+	Kind  Kind
+}
+
+// FlattenToRows flattens a JSON object into one Row per leaf, sorted by key, ready for a bulk
+// insert into an entity-attribute-value table. Kind is detected as for Options.TypedValues; when
+// that option is set, Value holds the unwrapped value and Kind the envelope's tag.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "address": {"zip": 10001}}`)
+//	rows, err := FlattenToRows(data, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(rows)
+//
+// Output:
+//
+//	[{address.zip 10001 integer} {name John string}]
+func FlattenToRows(data []byte, options Options) ([]Row, error) {
+	flattened, err := FlattenJSON(data, options)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, 0, len(flattened))
+	for _, key := range keyOrder(flattened, options) {
+		row := Row{Key: key, Value: flattened[key]}
+		if typed, ok := row.Value.(TypedValue); ok {
+			row.Value, row.Kind = typed.Value, typed.Type
+		} else {
+			row.Kind = valueType(row.Value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// FlattenToGoLiteral flattens a JSON object and returns Go source declaring varName as a
+// map[string]interface{} literal of the flattened map, with sorted keys and quoted strings, for use
+// as a test fixture. Integral numbers are written as integer literals, so they compile to int
+// values. The source is gofmt-formatted; values without a Go literal form are written with %#v.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "age": 30}`)
+//	src, err := FlattenToGoLiteral(data, "fixture", DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Print(string(src))
+//
+// Output:
+//
+//	var fixture = map[string]interface{}{
+//		"age":  30,
+//		"name": "John",
+//	}
+func FlattenToGoLiteral(data []byte, varName string, options Options) ([]byte, error) {
+	flattened, err := FlattenJSON(data, options)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString("var " + varName + " = map[string]interface{}{\n")
+	for _, key := range keyOrder(flattened, options) {
+		b.WriteString(strconv.Quote(key))
+		b.WriteString(": ")
+		b.WriteString(goLiteral(flattened[key]))
+		b.WriteString(",\n")
+	}
+	b.WriteString("}\n")
+	return format.Source([]byte(b.String()))
+}
+
+// goLiteral is a helper function that writes a leaf value as a Go literal.
+func goLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		if floatType(v) == TypeInteger {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+		if f, err := v.Float64(); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return strconv.Quote(v.String())
+	}
+	return fmt.Sprintf("%#v", value)
+}
+
+// Edge is a single parent-child relationship of a document tree, as produced by FlattenToEdges.
+// Parent and Child are flattened keys, with "" for the top level; leaves carry their value.
+type Edge struct {
+	Parent string
+	Child  string
+	IsLeaf bool
+	Value  interface{}
+}
+
+// FlattenToEdges flattens a JSON object into the edges of its tree, one per object member or
+// array element, for loading the structure into a graph database. Edges are sorted by child key,
+// so every parent appears before its children; empty objects and arrays are nodes without
+// children.
+//
+// Example:
+//
+//	data := []byte(`{"address": {"city": "New York"}}`)
+//	edges, err := FlattenToEdges(data, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(edges)
+//
+// Output:
+//
+//	[{ address false <nil>} {address address.city true New York}]
+func FlattenToEdges(data []byte, options Options) ([]Edge, error) {
+	flattened, present, err := FlattenJSONWithPresence(data, options)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(present))
+	for key := range present {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	edges := make([]Edge, 0, len(keys))
+	for _, key := range keys {
+		segments := splitKey(key, options)
+		edge := Edge{Parent: joinSegments(segments[:len(segments)-1], options), Child: key}
+		edge.Value, edge.IsLeaf = flattened[key]
+		edges = append(edges, edge)
+	}
+	return edges, nil
+}
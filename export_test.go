@@ -0,0 +1,310 @@
+package goflat_test
+
+import (
+	"bytes"
+	"errors"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFlattenColumns(t *testing.T) {
+	// Test case 1: Records with overlapping keys
+	records := []map[string]interface{}{
+		{"name": "John", "address": map[string]interface{}{"city": addressCity}},
+		{"name": "Jane", "address": map[string]interface{}{"city": "Boston"}},
+	}
+	expected := map[string][]interface{}{
+		"name":         {"John", "Jane"},
+		addressCityKey: {addressCity, "Boston"},
+	}
+	options := goflat.DefaultOptions()
+	result, err := goflat.FlattenColumns(records, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Columns do not match expected result: %v", result)
+	}
+
+	// Test case 2: Records with disjoint keys are padded with nil
+	records = []map[string]interface{}{
+		{"name": "John"},
+		{"age": 25},
+		{"hobbies": []interface{}{hobbies0}},
+	}
+	expected = map[string][]interface{}{
+		"name":      {"John", nil, nil},
+		"age":       {nil, 25, nil},
+		hobbies0Key: {nil, nil, hobbies0},
+	}
+	result, err = goflat.FlattenColumns(records, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Columns do not match expected result: %v", result)
+	}
+
+	// Test case 3: A record that fails to flatten is reported
+	options.MaxKeys = 1
+	records = append(records, map[string]interface{}{"name": "Jane", "age": 31})
+	_, err = goflat.FlattenColumns(records, options)
+	if !errors.Is(err, goflat.ErrTooManyKeys) {
+		t.Errorf("Expected ErrTooManyKeys, got %v", err)
+	}
+}
+
+func TestFlattenToNDJSON(t *testing.T) {
+	// Test case 1: Sorted lines for a nested document
+	data := []byte(`{"name": "John", "age": 30, "address": {"city": "New York"}, "hobbies": ["reading"]}`)
+	options := goflat.DefaultOptions()
+	options.SortKeys = true
+	var buf bytes.Buffer
+	if err := goflat.FlattenToNDJSON(data, &buf, options); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected := `{"key":"address.city","value":"New York"}
+{"key":"age","value":30}
+{"key":"hobbies.0","value":"reading"}
+{"key":"name","value":"John"}
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected NDJSON output:\n%s", buf.String())
+	}
+
+	// Test case 2: Unsorted output contains the same lines
+	options.SortKeys = false
+	buf.Reset()
+	if err := goflat.FlattenToNDJSON(data, &buf, options); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	sort.Strings(lines)
+	if strings.Join(lines, "\n")+"\n" != expected {
+		t.Errorf("Unexpected NDJSON output:\n%s", buf.String())
+	}
+
+	// Test case 3: Invalid JSON is reported
+	if err := goflat.FlattenToNDJSON([]byte(`{`), &buf, options); err == nil {
+		t.Errorf("Expected error when unmarshalling invalid JSON")
+	}
+}
+
+func TestFlattenJSONToBytes(t *testing.T) {
+	// Test case 1: The flattened map is encoded with sorted, quoted keys
+	data := []byte(`{"name": "John", "true": false, "1": {"x": null}}`)
+	options := goflat.DefaultOptions()
+	options.QuoteKeys = true
+	out, err := goflat.FlattenJSONToBytes(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if string(out) != `{"1.x":null,"name":"John","true":false}` {
+		t.Errorf("Unexpected JSON output: %s", out)
+	}
+}
+
+func TestFlattenToYAML(t *testing.T) {
+	// Test case 1: Boolean-looking and numeric keys are quoted with QuoteKeys
+	data := []byte(`{"true": 1, "42": "answer", "address": {"city": "New York"}}`)
+	options := goflat.DefaultOptions()
+	options.SortKeys = true
+	options.QuoteKeys = true
+	var buf bytes.Buffer
+	if err := goflat.FlattenToYAML(data, &buf, options); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected := `"42": "answer"
+"address.city": "New York"
+"true": 1
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected YAML output: %s", buf.String())
+	}
+
+	// Test case 2: Without QuoteKeys only unsafe keys are quoted
+	data = []byte(`{"true": 1, "a: b": 2, "-x": 3}`)
+	options.QuoteKeys = false
+	buf.Reset()
+	if err := goflat.FlattenToYAML(data, &buf, options); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected = `"-x": 3
+"a: b": 2
+true: 1
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected YAML output: %s", buf.String())
+	}
+}
+
+func TestFlattenToPrometheus(t *testing.T) {
+	// Test case 1: Numeric leaves become metric lines and other leaves are skipped
+	data := []byte(`{"name": "John", "age": 30, "active": true, "address": {"geo": {"lat": 40.7, "lng": -74}}, "scores": [1.5, 2], "top-level": 1e21}`)
+	out, err := goflat.FlattenToPrometheus(data, "user", goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected := `user_address_geo_lat 40.7
+user_address_geo_lng -74
+user_age 30
+user_scores_0 1.5
+user_scores_1 2
+user_top_level 1e+21
+`
+	if string(out) != expected {
+		t.Errorf("Unexpected Prometheus output: %s", out)
+	}
+
+	// Test case 2: Without a prefix, names cannot start with a digit
+	out, err = goflat.FlattenToPrometheus([]byte(`{"0": {"x": 1}}`), "", goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if string(out) != "__x 1\n" {
+		t.Errorf("Unexpected Prometheus output: %s", out)
+	}
+}
+
+func TestFlattenJSONToBytesLimited(t *testing.T) {
+	data := []byte(`{"name": "John", "address": {"city": "New York"}}`)
+	expected, err := goflat.FlattenJSONToBytes(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Fatalf(errorFlatteningJSON, err)
+	}
+
+	// Test case 1: Output exactly at the limit is returned whole
+	out, err := goflat.FlattenJSONToBytesLimited(data, len(expected), goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if string(out) != string(expected) {
+		t.Errorf("Unexpected JSON output: %s", out)
+	}
+
+	// Test case 2: Output one byte over the limit fails
+	_, err = goflat.FlattenJSONToBytesLimited(data, len(expected)-1, goflat.DefaultOptions())
+	if !errors.Is(err, goflat.ErrOutputTooLarge) {
+		t.Errorf("Expected ErrOutputTooLarge, got %v", err)
+	}
+
+	// Test case 3: Keys alone over the limit fail before encoding
+	_, err = goflat.FlattenJSONToBytesLimited(data, 10, goflat.DefaultOptions())
+	if !errors.Is(err, goflat.ErrOutputTooLarge) {
+		t.Errorf("Expected ErrOutputTooLarge, got %v", err)
+	}
+
+	// Test case 4: An empty document fits a tiny limit
+	out, err = goflat.FlattenJSONToBytesLimited([]byte(`{}`), 2, goflat.DefaultOptions())
+	if err != nil || string(out) != "{}" {
+		t.Errorf("Unexpected result for an empty document: %s (%v)", out, err)
+	}
+}
+
+func TestFlattenToRows(t *testing.T) {
+	data := []byte(`{"name": "John", "age": 30, "score": 9.5, "active": true, "nick": null,
+		"address": {"city": "New York", "geo": {"lat": 40.7}}, "hobbies": ["reading"]}`)
+
+	// Test case 1: One row per leaf, sorted by key, with detected kinds
+	expected := []goflat.Row{
+		{Key: "active", Value: true, Kind: goflat.TypeBoolean},
+		{Key: addressCityKey, Value: addressCity, Kind: goflat.TypeString},
+		{Key: "address.geo.lat", Value: 40.7, Kind: goflat.TypeNumber},
+		{Key: "age", Value: float64(30), Kind: goflat.TypeInteger},
+		{Key: hobbies0Key, Value: hobbies0, Kind: goflat.TypeString},
+		{Key: "name", Value: "John", Kind: goflat.TypeString},
+		{Key: "nick", Value: nil, Kind: goflat.TypeNull},
+		{Key: "score", Value: 9.5, Kind: goflat.TypeNumber},
+	}
+	rows, err := goflat.FlattenToRows(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Rows do not match expected result: %v", rows)
+	}
+
+	// Test case 2: Typed value envelopes are unwrapped into the row
+	options := goflat.DefaultOptions()
+	options.TypedValues = true
+	rows, err = goflat.FlattenToRows(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Rows do not match expected result: %v", rows)
+	}
+}
+
+func TestFlattenToGoLiteral(t *testing.T) {
+	data := []byte(`{"name": "John \"JJ\"", "age": 30, "score": 9.5, "active": true, "nick": null,
+		"address": {"city": "New York"}, "hobbies": ["reading"]}`)
+
+	// Test case 1: The literal matches the golden source
+	src, err := goflat.FlattenToGoLiteral(data, "fixture", goflat.DefaultOptions())
+	if err != nil {
+		t.Fatalf(errorFlatteningJSON, err)
+	}
+	expected := `var fixture = map[string]interface{}{
+	"active":       true,
+	"address.city": "New York",
+	"age":          30,
+	"hobbies.0":    "reading",
+	"name":         "John \"JJ\"",
+	"nick":         nil,
+	"score":        9.5,
+}
+`
+	if string(src) != expected {
+		t.Errorf("Unexpected Go literal:\n%s", src)
+	}
+
+	// Test case 2: The literal parses as Go source
+	file := "package fixtures\n\n" + string(src)
+	if _, err := parser.ParseFile(token.NewFileSet(), "fixture.go", file, 0); err != nil {
+		t.Errorf("Generated literal does not parse: %v", err)
+	}
+}
+
+func TestFlattenToEdges(t *testing.T) {
+	// Test case 1: A two-level document with an array becomes parent-child edges
+	data := []byte(`{"name": "John", "address": {"city": "New York"}, "hobbies": ["reading", "gaming"], "meta": {}}`)
+	expected := []goflat.Edge{
+		{Parent: "", Child: "address"},
+		{Parent: "address", Child: addressCityKey, IsLeaf: true, Value: addressCity},
+		{Parent: "", Child: "hobbies"},
+		{Parent: "hobbies", Child: hobbies0Key, IsLeaf: true, Value: hobbies0},
+		{Parent: "hobbies", Child: hobbies1Key, IsLeaf: true, Value: hobbies1},
+		{Parent: "", Child: "meta"},
+		{Parent: "", Child: "name", IsLeaf: true, Value: "John"},
+	}
+	edges, err := goflat.FlattenToEdges(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(edges, expected) {
+		t.Errorf("Edges do not match expected result: %v", edges)
+	}
+
+	// Test case 2: Bracketed indices hang off their array
+	options := goflat.DefaultOptions()
+	options.ArrayNotation = goflat.NotationBracket
+	edges, err = goflat.FlattenToEdges([]byte(`{"m": [[true]]}`), options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected = []goflat.Edge{
+		{Parent: "", Child: "m"},
+		{Parent: "m", Child: "m[0]"},
+		{Parent: "m[0]", Child: "m[0][0]", IsLeaf: true, Value: true},
+	}
+	if !reflect.DeepEqual(edges, expected) {
+		t.Errorf("Edges do not match expected result: %v", edges)
+	}
+}
@@ -3,17 +3,393 @@
 package goflat
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
+	"reflect"
+	"strconv"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // Options represents the options for flattening and unflattening JSON.
 type Options struct {
 	KeyDelimiter string // The delimiter to use for separating keys in the flattened structure
 	MaxDepth     int    // The maximum depth for flattening
+
+	// EscapeChar, when non-zero, is written before delimiters, brackets (with NotationBracket)
+	// and itself inside map keys so that such keys survive a round trip. 0 disables escaping.
+	EscapeChar rune
+	// ArrayNotation selects how array indices are written: "a.0" (NotationDot, the default)
+	// or "a[0]" (NotationBracket).
+	ArrayNotation Notation
+	// KeyCase changes the case of map keys during flatten. Array indices are never affected.
+	KeyCase Case
+
+	// TagArrays records an extra ArrayMarkerKey child (e.g. "hobbies.#type" = "array") for every
+	// flattened array. Unflattening with TagArrays set rebuilds exactly the marked nodes as arrays
+	// and keeps numeric-keyed maps as maps. Note that this adds one key per array to the output.
+	TagArrays bool
+	// CollapseUniformArrays stores arrays of objects that all have the same scalar fields column-style,
+	// so items.0.name and items.1.name become a single items.name holding []interface{} of the values.
+	// This is lossy and disabled by default.
+	CollapseUniformArrays bool
+	// IndexBucketSize, when positive, splits every array index i into a "bucket_<i/size>" segment
+	// followed by the in-bucket index i%size, e.g. items.bucket_3.7 for index 307 with a size of 100.
+	// Unflattening with the same size joins the two segments back into one index. 0 disables bucketing.
+	IndexBucketSize int
+	// UseStringer stores the String() result of leaf values implementing fmt.Stringer,
+	// such as time.Duration, instead of the value itself.
+	UseStringer bool
+	// ExpandLeaf, when set, is called for every leaf. A non-nil result replaces the leaf with the
+	// returned entries, whose keys are relative to the leaf key (e.g. "lat" under "coords" becomes
+	// "coords.lat"). Returning nil keeps the leaf as it is.
+	ExpandLeaf func(key string, value interface{}) map[string]interface{}
+	// PadIndexWidth, when positive, zero-pads array indices to this many digits (items.0010) so that
+	// lexical key order matches numeric order. Unflattening with the same width reads every digit-only
+	// segment at least that wide as an index. Indices wider than the width are written unpadded.
+	PadIndexWidth int
+	// PadIndexStrict makes FlattenJSON fail with ErrIndexTooWide instead of widening an index that
+	// does not fit PadIndexWidth.
+	PadIndexStrict bool
+	// MaxIndexDigits, when positive, replaces array indices with more digits than this by a stable
+	// hash of exactly MaxIndexDigits base-36 characters, at least one of them a letter, keeping
+	// every segment within that width for fixed-width key stores. Colliding hashes in one array
+	// take the next free segment; an array with more elements than there are segments fails with
+	// ErrIndexTooWide. FlattenJSONWithIndexHashes returns the mapping that
+	// UnflattenJSONWithIndexHashes uses to restore the indices.
+	MaxIndexDigits int
+	// MinArrayKeys is the minimum number of contiguous index keys a node needs to be rebuilt as an
+	// array on unflatten, so that a lone {"0": x} can stay a map. Nodes marked by TagArrays are
+	// always rebuilt. 0 and 1 both allow single-element arrays.
+	MinArrayKeys int
+	// PrefixFromPath names a flattened key whose value is used to prefix every other key, e.g. "id"
+	// turns "name" into "alice.name" for a document with id "alice". The named key is kept unprefixed.
+	// Documents without the key are left unprefixed unless PrefixFromPathRequired is set.
+	PrefixFromPath string
+	// PrefixFromPathRequired makes a missing PrefixFromPath key fail with ErrPrefixPathMissing.
+	PrefixFromPathRequired bool
+	// ArraysRequireBrackets makes unflatten rebuild arrays only from bracketed indices, so "a[0]" is
+	// always an array element while "a.0" is always the map key "0". Only useful with NotationBracket.
+	ArraysRequireBrackets bool
+	// MixedNotation makes keys parse with bracketed indices and delimiters mixed in one key, as
+	// written by other tools, so unflattening "a.b[0].c[1].d" rebuilds arrays at "b" and "c" even
+	// with NotationDot. It only affects parsing; flattened keys follow ArrayNotation.
+	MixedNotation bool
+	// TrimPrefix is removed, together with the delimiter following it, from the start of every
+	// flattened key, e.g. "data.attributes" turns "data.attributes.name" into "name".
+	// Keys without the prefix are kept as they are unless TrimPrefixDropOthers is set.
+	TrimPrefix string
+	// TrimPrefixDropOthers drops keys that do not start with TrimPrefix.
+	TrimPrefixDropOthers bool
+	// PreserveNumberText makes FlattenJSON store numbers as json.Number holding their exact source
+	// text (1.0, 1e3, 100000000000000000), so re-encoding reproduces the original bytes.
+	PreserveNumberText bool
+	// JSONPointer writes keys as RFC 6901 JSON Pointers: every key starts with the delimiter and
+	// "~" and the delimiter inside map keys are escaped as "~0" and "~1". Use JSONPointerOptions.
+	// UnflattenJSON and PathSplit parse such keys back, undoing the escaping.
+	JSONPointer bool
+	// MongoKeys makes flattening fail with ErrInvalidFieldName for map keys that cannot appear in a
+	// MongoDB dot notation path: keys containing ".", keys starting with "$" and empty keys. Keys
+	// are checked after the other key rewriting options. Use MongoOptions.
+	MongoKeys bool
+	// PrefixCacheSize bounds the number of keys a Flattener created by NewFlattener caches for
+	// reuse across calls. 0 uses DefaultPrefixCacheSize. Other functions ignore it.
+	PrefixCacheSize int
+	// MaxBreadth, when positive, is the maximum number of members a single object may have.
+	// Wider objects fail with ErrBreadthExceeded, bounding the shape of untrusted input.
+	MaxBreadth int
+	// TypeEncoders maps concrete Go types to functions that convert values of that type before they
+	// are stored, e.g. []byte to base64 or time.Time to RFC 3339. They take precedence over UseStringer
+	// and over walking slices, so a []byte encoder keeps byte slices whole.
+	TypeEncoders map[reflect.Type]func(interface{}) interface{}
+	// SortKeys makes WalkLeaves and the emitters built on it produce keys in sorted order.
+	// Sorting needs every key up front, so the walk is no longer streamed.
+	SortKeys bool
+	// KeySort, when set, replaces byte order as the comparator used when SortKeys is set and by the
+	// emitters that always sort their output: FlattenJSONToBytes, FlattenJSONToBytesLimited,
+	// FlattenToRows, FlattenToPrometheus and FlattenToGoLiteral. It reports whether a sorts before
+	// b; NaturalKeySort orders numeric runs numerically.
+	KeySort func(a, b string) bool
+	// OpaquePaths lists glob patterns ("*" matches any run of characters, "?" a single one) of
+	// flattened keys whose values are stored whole, without descending into them.
+	OpaquePaths []string
+	// LeafVisitor, when set, is called for every leaf with the segments of its key as split by
+	// PathSplit, e.g. ["users", "0", "name"], so decisions can depend on ancestor keys.
+	// The path is the walked one, before TrimPrefix and PrefixFromPath rewrite keys.
+	LeafVisitor func(path []string, value interface{})
+	// OnEnterObject and OnLeaveObject, when set, are called before and after the members of every
+	// object are walked, and OnEnterArray and OnLeaveArray around the elements of every array, with
+	// the segments of the container's key as LeafVisitor gets them. The top-level object has an empty
+	// path. Together with LeafVisitor they form a SAX-like stream of events in walk order. Arrays
+	// stored whole, e.g. with PreserveArrays, are leaves and raise no array events.
+	OnEnterObject func(path []string)
+	OnLeaveObject func(path []string)
+	OnEnterArray  func(path []string, length int)
+	OnLeaveArray  func(path []string)
+	// ElideBase64 replaces string leaves that are base64 data, or data URIs with base64 payloads,
+	// with a placeholder such as "<base64:2048 bytes>" giving the decoded size. Only strings of at
+	// least Base64Threshold characters are considered, so short words that happen to be valid
+	// base64 are kept.
+	ElideBase64 bool
+	// Base64Threshold is the minimum string length ElideBase64 considers. 0 means 1024.
+	Base64Threshold int
+	// NormalizeUnicodeKeys normalizes map keys to Unicode NFC before they are written, so canonically
+	// equivalent keys, such as a precomposed "é" and "e" plus a combining accent, become the same key.
+	// This can merge distinct source keys, in which case one of their values is kept. Off by default.
+	NormalizeUnicodeKeys bool
+	// MaxKeys, when positive, is the maximum number of flattened keys a document may produce.
+	// Larger documents fail with ErrTooManyKeys.
+	MaxKeys int
+	// ArrayKeyField names a field of array elements whose value replaces the index segment, so a
+	// "users" element with "id": "alice" is flattened under "users.alice" instead of "users.0".
+	// Elements that are not objects or lack the field keep their index. Two elements with the same
+	// value fail with ErrKeyCollision. Such arrays unflatten as objects.
+	ArrayKeyField string
+	// EmitArrayLength adds a sidecar key holding the element count of every flattened array, named
+	// by appending ArrayLengthKey to the array key, e.g. "hobbies.#" = 2. Unflattening with the option
+	// set consumes these keys and uses them to rebuild empty arrays.
+	EmitArrayLength bool
+	// QuoteKeys makes text emitters such as FlattenToYAML write every key as a quoted string, even
+	// keys like true, null or 42 that would otherwise be written bare and read back as non-strings.
+	// JSON output always quotes keys, so FlattenJSONToBytes is unaffected.
+	QuoteKeys bool
+	// BoolFormat selects how boolean leaves are stored: as true/false (BoolTrueFalse, the default) or
+	// as the integers 1/0 (BoolOneZero). UnflattenJSON does not convert 1/0 back, so documents
+	// flattened with BoolOneZero unflatten with numbers in place of booleans.
+	BoolFormat BoolFormat
+	// NonFiniteFloatPolicy selects what happens to NaN and infinite float leaves, which JSON cannot
+	// represent: they are kept (NonFiniteKeep, the default), rejected with ErrNonFiniteFloat
+	// (NonFiniteError), stored as nil (NonFiniteNull) or stored as "NaN", "+Inf" or "-Inf"
+	// (NonFiniteString).
+	NonFiniteFloatPolicy NonFinitePolicy
+	// PreserveArrays stores every array, including typed slices, whole as a single leaf under its
+	// key, while maps are still flattened. Maps inside preserved arrays are not flattened.
+	PreserveArrays bool
+	// Derive, when set, is called for every leaf and the entries it returns are added to the output
+	// under their own keys, which are absolute flattened keys, e.g. "price_bucket" derived from
+	// "price". Derived keys that collide with walked keys or with each other fail with
+	// ErrKeyCollision unless DeriveOverwrite is set, in which case derived entries win.
+	// Derived entries are added before TrimPrefix and PrefixFromPath rewrite keys.
+	Derive func(key string, value interface{}) map[string]interface{}
+	// DeriveOverwrite lets Derive entries replace existing entries instead of failing.
+	DeriveOverwrite bool
+	// LenientUnflatten makes unflattening skip malformed keys instead of failing: keys with an empty
+	// segment, such as "a..b" or "a.", and keys that conflict with keys added before them.
+	// UnflattenJSONWithSkipped reports the skipped keys.
+	LenientUnflatten bool
+	// ExpandJSONBytes parses []byte and json.RawMessage leaves as JSON and flattens the decoded value
+	// in their place, for documents holding pre-serialized sub-objects. Bytes that are not valid
+	// JSON are kept as leaves unless ExpandJSONBytesStrict is set, which fails with ErrInvalidJSONBytes.
+	ExpandJSONBytes bool
+	// ExpandJSONBytesStrict makes ExpandJSONBytes reject bytes that are not valid JSON.
+	ExpandJSONBytesStrict bool
+	// ExpandRawMessage parses json.RawMessage leaves, but not other byte slices, as JSON and flattens
+	// the decoded value under the leaf's key, like ExpandJSONBytes does for both.
+	ExpandRawMessage bool
+	// IndexLabel, when set, is called with the index and length of every array element and a
+	// non-empty result replaces the index segment, e.g. "samples.first" and "samples.last" for a
+	// labeler naming the ends of an array. Empty results keep the numeric index, and a label
+	// repeated within one array fails with ErrKeyCollision. Labeled arrays unflatten as objects.
+	IndexLabel func(i, n int) string
+	// IndexDelimiter, when set, is written before array indices instead of KeyDelimiter, so that
+	// indices stand out as in "a.b#0" with "#". Unflattening with the same delimiter reads a run of
+	// digits after it as an index; set EscapeChar to keep map keys containing it intact.
+	// It has no effect with NotationBracket.
+	IndexDelimiter string
+	// SlugifyKeys rewrites every flattened key into a form safe for URL paths and metric names:
+	// within each segment, ASCII letters, digits, "_" and "-" are kept and every other run of
+	// characters, spaces included, becomes SlugReplacement. This is lossy, so slugified keys do
+	// not round-trip. Keys that become identical fail with ErrKeyCollision unless
+	// SlugSuffixCollisions is set.
+	SlugifyKeys bool
+	// SlugReplacement is the character SlugifyKeys writes for disallowed characters. 0 means '_'.
+	SlugReplacement rune
+	// SlugSuffixCollisions makes SlugifyKeys number colliding keys instead of failing: in sorted
+	// order of their source keys, the second becomes "key_2", the third "key_3" and so on.
+	SlugSuffixCollisions bool
+	// ASCIIOnlyKeys rewrites every map key segment of the flattened keys to ASCII: accented letters
+	// lose their accents ("café" becomes "cafe"), a few letters are spelled out ("ß" becomes "ss")
+	// and other non-ASCII characters are dropped, with a segment left empty written as "_". This is
+	// lossy, so such keys do not round-trip. Keys that become identical fail with ErrKeyCollision
+	// unless ASCIISuffixCollisions is set.
+	ASCIIOnlyKeys bool
+	// ASCIISuffixCollisions makes ASCIIOnlyKeys number colliding keys instead of failing, as
+	// SlugSuffixCollisions does for SlugifyKeys.
+	ASCIISuffixCollisions bool
+	// TrackOrder makes FlattenJSONOrdered record the flattened keys in the order their values appear
+	// in the source, which UnflattenJSONOrdered uses to restore member order. Without it,
+	// FlattenJSONOrdered behaves like FlattenJSON and returns no order.
+	TrackOrder bool
+	// CompactBelowDepth, when positive, stores every object or array whose key has that many
+	// segments as a single compact JSON string leaf instead of flattening it, e.g. 2 turns
+	// {"a": {"b": {"c": 1}}} into "a.b" = `{"c":1}`. Unlike MaxDepth, the leaf is a string, not
+	// the nested value. Object members are written in sorted order.
+	CompactBelowDepth int
+	// EmptyKeyReplacement, when set, is written in place of empty map keys, so {"": 1} flattens to
+	// "_empty_" rather than "" with a replacement of "_empty_". Unflattening with the same
+	// replacement turns such segments back into empty keys, which also affects source keys that
+	// happen to equal the replacement.
+	EmptyKeyReplacement string
+	// UnwrapKeys lists a chain of wrapper keys peeled off before flattening when the document is
+	// exactly that chain of single-member objects, e.g. ["data"] flattens {"data": {"name": "John"}}
+	// to "name" instead of "data.name". Documents of another shape are flattened as they are unless
+	// UnwrapKeysRequired is set, which fails with ErrUnwrapMismatch.
+	UnwrapKeys []string
+	// UnwrapKeysRequired makes documents that do not match UnwrapKeys fail.
+	UnwrapKeysRequired bool
+	// RedactionMarker is the value FlattenRedacted stores in place of sensitive values. When empty,
+	// DefaultRedactionMarker is used.
+	RedactionMarker string
+	// TrimSpace trims leading and trailing white space from string leaves and from map keys while
+	// flattening. Keys that only differ in surrounding white space fail with ErrKeyCollision unless
+	// TrimSpaceLastWins is set.
+	TrimSpace bool
+	// TrimSpaceLastWins resolves keys colliding after TrimSpace by keeping the member whose original
+	// key sorts last, dropping the others.
+	TrimSpaceLastWins bool
+	// ArrayPolicy chooses how arrays are flattened depending on what they hold, e.g. keying object
+	// arrays by a field while keeping scalar arrays whole. The zero value leaves arrays to the other
+	// options. See ArrayPolicy for the order in which the rules apply.
+	ArrayPolicy ArrayPolicy
+	// InternStrings makes equal string leaves share one backing string within a flatten call, so
+	// documents with many repeated values keep a single copy of each once the input is released.
+	// The table is capped at the first 4096 distinct strings, so documents of unique values do not
+	// grow it without bound. The output is unchanged.
+	InternStrings bool
+	// FlagUnknownKeys makes FlattenValidate report keys that its schema does not list.
+	FlagUnknownKeys bool
+	// HoistCommonFields stores a scalar field that every element of an array of objects holds with
+	// the same value once under the array, e.g. "orders.currency" instead of "orders.0.currency" and
+	// "orders.1.currency", and drops it from the elements. Arrays with fewer than two elements are
+	// left as they are. Hoisting is lossy: unflattening cannot put the fields back into the elements,
+	// and rebuilds such arrays as objects. Disabled by default.
+	HoistCommonFields bool
+	// EmitNumericAggregates adds "min", "max", "sum" and "avg" sidecar keys under every flattened
+	// array whose elements are all numbers, e.g. "scores.avg" next to "scores.0". Empty arrays and
+	// arrays holding anything else get none. Unflattening rebuilds such arrays as objects.
+	EmitNumericAggregates bool
+	// BytesEncoding selects how []byte leaves are stored: whole (BytesRaw, which JSON encoders
+	// later write as base64), or as a standard base64 or lowercase hex string.
+	BytesEncoding BytesEncoding
+	// MaxStringLength, when positive, truncates string leaves longer than this many characters to
+	// that length followed by "…". With StringLengthSidecar set, each truncated leaf also gets a
+	// sidecar entry named by appending StringLengthSuffix to its key, e.g. "bio#len", holding the
+	// original length in characters.
+	MaxStringLength int
+	// StringLengthSidecar adds the original length of strings truncated by MaxStringLength.
+	StringLengthSidecar bool
+	// RenameRegex rewrites every flattened key once the walk is done: each rule replaces the matches
+	// of its Pattern in the key with Replace, which may refer to submatches as in
+	// regexp.Regexp.ReplaceAllString, and the rules apply in order. Keys that end up identical fail
+	// with ErrKeyCollision unless RenameRegexLastWins is set.
+	RenameRegex []RegexRename
+	// RenameRegexLastWins resolves keys colliding after RenameRegex by keeping the entry whose
+	// original key sorts last, dropping the others.
+	RenameRegexLastWins bool
+	// KindPrefixKeys prepends a short tag naming the kind of each value to its flattened key, after
+	// every other key rewrite, e.g. "str:name", "num:age" and "bool:active", so flattened documents
+	// are easier to scan in logs. The tags are str, num, bool, null, arr, obj and any. This is a
+	// debugging aid: the keys do not unflatten back into the document.
+	KindPrefixKeys bool
+	// KeyBuilder, when set, builds every flattened key in place of KeyDelimiter, ArrayNotation,
+	// IndexDelimiter, EscapeChar and JSONPointer, which it is then responsible for. Map keys reach
+	// it after NormalizeUnicodeKeys, KeyCase and EmptyKeyReplacement, and indices before any
+	// padding, bucketing or base-36 rewriting, which no longer apply. When it also implements KeyParser,
+	// keys are parsed back with it. See NewKeyBuilder for the built-in schemes.
+	KeyBuilder KeyBuilder
+	// TypedValues stores every value as a TypedValue envelope carrying a type tag, e.g.
+	// {"type":"integer","value":30}. UnflattenJSON with TypedValues set unwraps the envelopes, and
+	// their decoded JSON form, restoring integers as int64 rather than float64.
+	TypedValues bool
+}
+
+// Case selects the casing applied to map keys by Options.KeyCase.
+type Case int
+
+const (
+	CaseNone  Case = iota // Keys are left as they are
+	CaseLower             // Keys are lowercased
+	CaseUpper             // Keys are uppercased
+)
+
+// NonFinitePolicy selects how NaN and infinite float leaves are handled by Options.NonFiniteFloatPolicy.
+type NonFinitePolicy int
+
+const (
+	NonFiniteKeep   NonFinitePolicy = iota // Non-finite floats are stored as they are
+	NonFiniteError                         // Non-finite floats fail with ErrNonFiniteFloat
+	NonFiniteNull                          // Non-finite floats are stored as nil
+	NonFiniteString                        // Non-finite floats are stored as "NaN", "+Inf" or "-Inf"
+)
+
+// BoolFormat selects how boolean leaves are stored by Options.BoolFormat.
+type BoolFormat int
+
+const (
+	BoolTrueFalse BoolFormat = iota // Booleans are stored as true and false
+	BoolOneZero                     // Booleans are stored as the integers 1 and 0
+)
+
+// ArrayMode selects how one class of arrays is flattened under Options.ArrayPolicy.
+type ArrayMode int
+
+const (
+	ArrayDefault       ArrayMode = iota // The array is flattened as the other options say
+	ArrayIndex                          // Elements are flattened under their index, e.g. "tags.0"
+	ArrayKeyByField                     // Elements are flattened under their ArrayKeyField value
+	ArrayPreserveWhole                  // The array is stored whole as a single leaf
+	ArrayJoinScalars                    // The elements are joined into one string leaf
+)
+
+// ArrayPolicy assigns an ArrayMode to each class of array. An array whose elements are all
+// objects is an object array, one without objects or arrays among its elements (including an
+// empty array) is a scalar array, and any other array is a mixed array.
+//
+// Arrays are handled in this order: PreserveArrays keeps every array whole; otherwise the mode for
+// the array's class applies; a mode of ArrayDefault, or one that does not apply to the class, falls
+// back to CollapseUniformArrays, ArrayKeyField, IndexLabel and plain indices, in that order.
+// ArrayKeyByField applies to object arrays only and needs Options.ArrayKeyField, and
+// ArrayJoinScalars applies to scalar arrays only; ArrayIndex and ArrayPreserveWhole apply to all.
+type ArrayPolicy struct {
+	ObjectArrays  ArrayMode // Mode for arrays of objects
+	ScalarArrays  ArrayMode // Mode for arrays of scalars
+	MixedArrays   ArrayMode // Mode for every other array
+	JoinSeparator string    // Separator for ArrayJoinScalars; "," when empty
 }
 
+// BytesEncoding selects how []byte leaves are stored by Options.BytesEncoding.
+type BytesEncoding int
+
+const (
+	BytesRaw    BytesEncoding = iota // Byte slices are stored as they are
+	BytesBase64                      // Byte slices are stored as standard base64 strings
+	BytesHex                         // Byte slices are stored as lowercase hex strings
+)
+
+// defaultBase64Threshold is the Base64Threshold used when the option is 0.
+const defaultBase64Threshold = 1024
+
+const (
+	// ArrayMarkerKey is the key segment used to mark arrays when Options.TagArrays is set.
+	ArrayMarkerKey = "#type"
+	// ArrayMarkerValue is the value stored under ArrayMarkerKey.
+	ArrayMarkerValue = "array"
+	// ArrayLengthKey is the key segment holding array lengths when Options.EmitArrayLength is set.
+	ArrayLengthKey = "#"
+	// StringLengthSuffix is appended to the keys of truncated strings to name the sidecar entries
+	// written when Options.StringLengthSidecar is set.
+	StringLengthSuffix = "#len"
+	// DefaultRedactionMarker is the value FlattenRedacted stores when Options.RedactionMarker is empty.
+	DefaultRedactionMarker = "[REDACTED]"
+)
+
 // DefaultOptions returns the default options for flattening and unflattening JSON.
 func DefaultOptions() Options {
 	return Options{
@@ -22,6 +398,24 @@ func DefaultOptions() Options {
 	}
 }
 
+// JSONPointerOptions returns options producing RFC 6901 JSON Pointer keys such as "/address/city"
+// and "/hobbies/0", as used by JSON Patch.
+func JSONPointerOptions() Options {
+	options := DefaultOptions()
+	options.KeyDelimiter = "/"
+	options.JSONPointer = true
+	return options
+}
+
+// MongoOptions returns options producing MongoDB dot notation keys such as "items.0.qty", ready to
+// be used as the fields of a $set update. Documents with field names MongoDB rejects in such paths
+// fail with ErrInvalidFieldName rather than producing keys that would update the wrong field.
+func MongoOptions() Options {
+	options := DefaultOptions()
+	options.MongoKeys = true
+	return options
+}
+
 // FlattenJSON flattens a JSON object into a map[string]interface{} using the specified options.
 // It supports flattening JSON arrays as well.
 //
@@ -40,15 +434,75 @@ func DefaultOptions() Options {
 //
 //	map[address.city:New York address.state:NY age:30 name:John]
 func FlattenJSON(data []byte, options Options) (map[string]interface{}, error) {
+	return FlattenWith(data, jsonDecoder(options), options)
+}
+
+// FlattenRaw flattens a JSON object held as a json.RawMessage like FlattenJSON, without copying it.
+//
+// Example:
+//
+//	raw := json.RawMessage(`{"address": {"city": "New York"}}`)
+//	flattened, err := FlattenRaw(raw, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(flattened)
+//
+// Output:
+//
+//	map[address.city:New York]
+func FlattenRaw(raw json.RawMessage, options Options) (map[string]interface{}, error) {
+	return FlattenJSON(raw, options)
+}
+
+// jsonDecoder is a helper function that returns the JSON decoder matching options.
+func jsonDecoder(options Options) func([]byte) (map[string]interface{}, error) {
+	if options.PreserveNumberText {
+		return decodeNumberText
+	}
+	return DecodeJSON
+}
+
+// FlattenWith decodes data with decode and flattens the result, letting callers flatten any
+// serialization format (YAML, TOML, CBOR, ...) without this package depending on it.
+// A nil decode uses DecodeJSON.
+//
+// Example:
+//
+//	decode := func(data []byte) (map[string]interface{}, error) {
+//		key, value, _ := strings.Cut(string(data), "=")
+//		return map[string]interface{}{key: value}, nil
+//	}
+//	flattened, err := FlattenWith([]byte("name=John"), decode, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(flattened)
+//
+// Output:
+//
+//	map[name:John]
+func FlattenWith(data []byte, decode func([]byte) (map[string]interface{}, error), options Options) (map[string]interface{}, error) {
+	if decode == nil {
+		decode = DecodeJSON
+	}
+	result, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return flattenMap(result, options)
+}
+
+// DecodeJSON decodes a JSON object with json.Unmarshal. It is the decoder used by FlattenJSON.
+func DecodeJSON(data []byte) (map[string]interface{}, error) {
 	var result map[string]interface{}
 	err := json.Unmarshal(data, &result)
 	if err != nil {
 		return nil, err
 	}
-
-	flattened := make(map[string]interface{})
-	flatten("", result, flattened, options.KeyDelimiter, options.MaxDepth)
-	return flattened, nil
+	return result, nil
 }
 
 // FlattenMap flattens a map[string]interface{} into a map[string]interface{} using the specified options.
@@ -71,71 +525,1548 @@ func FlattenJSON(data []byte, options Options) (map[string]interface{}, error) {
 // Output:
 //
 //	map[address.city:New York address.state:NY age:30 name:John]
+//
+// FlattenMap cannot report errors: options that fail (such as PadIndexStrict) only stop the
+// walk early. Use FlattenMapStrict when such options are set.
+//
+// FlattenMap reads data while walking it and assumes nothing mutates it during the call;
+// see FlattenMapSafe.
 func FlattenMap(data map[string]interface{}, options Options) map[string]interface{} {
-	flattened := make(map[string]interface{})
-	flatten("", data, flattened, options.KeyDelimiter, options.MaxDepth)
-	return flattened
+	f := newFlattener(options)
+	f.run(data)
+	return f.flattened
+}
+
+// FlattenMapSafe flattens a deep snapshot of data, so that the walk, including option callbacks
+// such as ExpandLeaf, never observes changes made to data after the snapshot is taken.
+// Taking the snapshot still reads data, so writers in other goroutines must be held off until
+// it returns, for example under the same lock they use; it is the walk that no longer needs it.
+// Nested map[string]interface{} and []interface{} values are copied; other leaves are shared.
+func FlattenMapSafe(data map[string]interface{}, options Options) map[string]interface{} {
+	return FlattenMap(DeepCopy(data), options)
+}
+
+// FlattenMapStrict flattens a map[string]interface{} like FlattenMap, but returns an error wrapping
+// ErrUnsupportedKind, naming the key, for leaves JSON cannot encode: channels, functions,
+// complex numbers and unsafe pointers. It also reports the errors FlattenMap has to drop.
+func FlattenMapStrict(data map[string]interface{}, options Options) (map[string]interface{}, error) {
+	f := newFlattener(options)
+	f.strict = true
+	f.run(data)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.flattened, nil
+}
+
+// flattenMap is a helper function that flattens data like FlattenMap and also reports
+// errors raised during the walk.
+func flattenMap(data map[string]interface{}, options Options) (map[string]interface{}, error) {
+	f := newFlattener(options)
+	f.run(data)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.flattened, nil
+}
+
+// flattener holds the state shared by a single flatten call.
+type flattener struct {
+	options   Options
+	flattened map[string]interface{}
+	positions map[string]int // Source offsets by key, only set when decoding with positions
+	err       error          // The first error met during the walk
+	strict    bool           // Whether leaves JSON cannot encode are rejected
+	// sink, when set, receives entries instead of the output map; see WalkLeaves.
+	sink func(key string, value interface{}) error
+	keys int             // The number of entries written so far, checked against MaxKeys
+	ctx  context.Context // When set, the walk stops with ctx.Err() once ctx is done
+	// bounded makes reaching MaxDepth at a container fail with ErrDepthExceeded instead of
+	// storing the container whole; see FlattenJSONBounded.
+	bounded bool
+	derived map[string]interface{} // Entries returned by Options.Derive, added by finish
+	// kinds, when set, receives the segment kinds of every entry, tracked in path during the
+	// walk; see FlattenJSONWithKinds.
+	kinds map[string]SegmentKinds
+	path  SegmentKinds
+	// targets and ancestors, when set, restrict the walk to the listed keys; see Project.
+	targets   map[string]bool
+	ancestors map[string]bool
+	interned  map[string]interface{} // The first leaf holding each string, per Options.InternStrings
+	hashes    map[string]int         // The index behind every hashed element key, per Options.MaxIndexDigits
+	presence  map[string]struct{}    // When set, receives every key walked; see FlattenJSONWithPresence
+	prefixes  *prefixCache           // When set, built keys are cached across calls; see Flattener
+	keyBuf    *[]byte                // When set, scratch space keys are built in; see FlattenJSONPooled
+	// losses, when set, receives the values overwritten under every key; see FlattenJSONWithLosses.
+	losses map[string][]interface{}
+}
+
+// newFlattener is a helper function that creates a flattener with an empty output map.
+func newFlattener(options Options) *flattener {
+	return &flattener{options: options, flattened: make(map[string]interface{})}
+}
+
+// run is a helper function that flattens every top-level member of data.
+func (f *flattener) run(data map[string]interface{}) {
+	if len(f.options.UnwrapKeys) > 0 {
+		unwrapped, ok := unwrap(data, f.options.UnwrapKeys)
+		switch {
+		case ok:
+			data = unwrapped
+		case f.options.UnwrapKeysRequired:
+			f.fail(fmt.Errorf("%w: %q", ErrUnwrapMismatch, f.options.UnwrapKeys))
+			return
+		}
+	}
+	if f.options.TrimSpace {
+		var ok bool
+		if data, ok = f.trimMembers("", data); !ok {
+			return
+		}
+	}
+	f.checkBreadth("", data)
+	if f.options.OnEnterObject != nil {
+		f.options.OnEnterObject(nil)
+	}
+	for key, val := range data {
+		if f.err != nil {
+			return
+		}
+		f.descend(f.rootKey(key), SegmentKey, val, f.options.MaxDepth)
+	}
+	if f.options.OnLeaveObject != nil {
+		f.options.OnLeaveObject(nil)
+	}
+	if f.err == nil {
+		f.finish()
+	}
+}
+
+// trimMembers is a helper function that returns the members of the object at key with white space
+// trimmed from their names per Options.TrimSpace. It reports false after failing on a collision.
+func (f *flattener) trimMembers(key string, m map[string]interface{}) (map[string]interface{}, bool) {
+	trimmed := make(map[string]interface{}, len(m))
+	sources := make(map[string]string, len(m))
+	for _, name := range sortedKeys(m) {
+		t := strings.TrimSpace(name)
+		if other, ok := sources[t]; ok {
+			if !f.options.TrimSpaceLastWins {
+				f.fail(fmt.Errorf("%w: %q and %q at %q both become %q", ErrKeyCollision, other, name, key, t))
+				return nil, false
+			}
+			if f.losses != nil {
+				lossKey := f.rootKey(t)
+				if key != "" {
+					lossKey = f.childKey(key, t)
+				}
+				f.lose(lossKey, trimmed[t])
+			}
+		}
+		sources[t] = name
+		trimmed[t] = m[name]
+	}
+	return trimmed, true
+}
+
+// unwrap is a helper function that peels the chain of single-member wrapper objects named by keys
+// off data, reporting false when data does not have that shape.
+func unwrap(data map[string]interface{}, keys []string) (map[string]interface{}, bool) {
+	for _, key := range keys {
+		value, ok := data[key]
+		if !ok || len(data) != 1 {
+			return nil, false
+		}
+		if p, ok := value.(positioned); ok {
+			value = p.value
+		}
+		if data, ok = value.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// finish is a helper function that applies the options rewriting whole flattened keys.
+func (f *flattener) finish() {
+	if f.derived != nil {
+		f.addDerived()
+	}
+	if f.options.TrimPrefix != "" {
+		f.trimPrefix()
+	}
+	if f.options.PrefixFromPath != "" {
+		f.prefixFromPath()
+	}
+	if f.options.SlugifyKeys {
+		f.slugifyKeys()
+	}
+	if f.options.ASCIIOnlyKeys {
+		f.rewriteSegments(transliterate, f.options.ASCIISuffixCollisions)
+	}
+	if len(f.options.RenameRegex) > 0 {
+		f.renameRegex()
+	}
+	if f.options.KindPrefixKeys {
+		f.prefixKinds()
+	}
+}
+
+// addDerived is a helper function that adds the entries collected from Options.Derive.
+func (f *flattener) addDerived() {
+	for key, value := range f.derived {
+		if old, ok := f.flattened[key]; ok {
+			if !f.options.DeriveOverwrite {
+				f.fail(fmt.Errorf("%w: derived key %q", ErrKeyCollision, key))
+				return
+			}
+			f.lose(key, old)
+		}
+		f.flattened[key] = value
+	}
+}
+
+// trimPrefix is a helper function that removes TrimPrefix and the delimiter after it from every
+// key, dropping or keeping keys without the prefix per TrimPrefixDropOthers.
+func (f *flattener) trimPrefix() {
+	prefix := f.options.TrimPrefix + f.options.KeyDelimiter
+	if f.options.TrimPrefixDropOthers {
+		for key := range f.flattened {
+			if !strings.HasPrefix(key, prefix) {
+				delete(f.flattened, key)
+			}
+		}
+	}
+	f.rekey(func(key string) string {
+		if f.options.JSONPointer && strings.HasPrefix(key, prefix) {
+			// Keep the leading delimiter every pointer starts with.
+			return key[len(f.options.TrimPrefix):]
+		}
+		return strings.TrimPrefix(key, prefix)
+	})
+}
+
+// prefixFromPath is a helper function that prefixes every key except PrefixFromPath itself with
+// the value stored under PrefixFromPath.
+func (f *flattener) prefixFromPath() {
+	source := f.options.PrefixFromPath
+	value, ok := f.flattened[source]
+	if !ok {
+		if f.options.PrefixFromPathRequired {
+			f.fail(fmt.Errorf("%w: %q", ErrPrefixPathMissing, source))
+		}
+		return
+	}
+	prefix := escapeSegment(fmt.Sprint(value), f.options) + f.options.KeyDelimiter
+	if f.options.JSONPointer {
+		prefix = f.options.KeyDelimiter + escapeSegment(fmt.Sprint(value), f.options)
+	}
+	f.rekey(func(key string) string {
+		if key == source {
+			return key
+		}
+		return prefix + key
+	})
+}
+
+// rekey is a helper function that renames every flattened key, and its recorded position, with rename.
+func (f *flattener) rekey(rename func(key string) string) {
+	// rename may read the entries, so every key is renamed before the map is replaced.
+	renamed := make(map[string]string, len(f.flattened))
+	for key := range f.flattened {
+		renamed[key] = rename(key)
+	}
+	flattened := make(map[string]interface{}, len(f.flattened))
+	for key, value := range f.flattened {
+		newKey := renamed[key]
+		if old, ok := flattened[newKey]; ok {
+			f.lose(newKey, old)
+		}
+		flattened[newKey] = value
+	}
+	f.flattened = flattened
+	if f.positions != nil {
+		positions := make(map[string]int, len(f.positions))
+		for key, offset := range f.positions {
+			if newKey, ok := renamed[key]; ok {
+				positions[newKey] = offset
+			}
+		}
+		f.positions = positions
+	}
+}
+
+// checkBreadth is a helper function that fails with ErrBreadthExceeded when the map at key has
+// more members than MaxBreadth allows.
+func (f *flattener) checkBreadth(key string, m map[string]interface{}) {
+	if f.options.MaxBreadth > 0 && len(m) > f.options.MaxBreadth {
+		f.fail(fmt.Errorf("%w: %d keys at %q", ErrBreadthExceeded, len(m), key))
+	}
+}
+
+// fail is a helper function that records err unless an earlier error is already recorded.
+func (f *flattener) fail(err error) {
+	if f.err == nil {
+		f.err = err
+	}
 }
 
 // flatten is a helper function that recursively flattens a JSON object.
-func flatten(prefix string, value interface{}, flattened map[string]interface{}, delimiter string, maxDepth int) {
+// Array elements are keyed by their index, so [[1, 2]] under "m" becomes "m.0.0" and "m.0.1".
+func (f *flattener) flatten(key string, value interface{}, maxDepth int) {
+	if f.err != nil {
+		return
+	}
+	if f.ctx != nil {
+		if err := f.ctx.Err(); err != nil {
+			f.fail(err)
+			return
+		}
+	}
+	if p, ok := value.(positioned); ok {
+		f.positions[key] = p.offset
+		value = p.value
+	}
+	if f.targets != nil {
+		if f.targets[key] && isContainer(value) {
+			f.store(key, value)
+			return
+		}
+		if !f.targets[key] && !f.ancestors[key] {
+			return
+		}
+	}
 	if maxDepth == 0 {
-		flattened[strings.TrimSuffix(prefix, delimiter)] = value
+		if f.bounded && isContainer(value) {
+			f.fail(fmt.Errorf("%w: %q", ErrDepthExceeded, key))
+			return
+		}
+		f.store(key, value)
+		return
+	}
+
+	if len(f.options.OpaquePaths) > 0 && matchAny(f.options.OpaquePaths, key) {
+		f.store(key, value)
+		return
+	}
+	if encode, ok := f.options.TypeEncoders[reflect.TypeOf(value)]; ok {
+		f.store(key, encode(value))
+		return
+	}
+
+	if f.options.CompactBelowDepth > 0 && isContainer(value) && f.options.MaxDepth-maxDepth+1 >= f.options.CompactBelowDepth {
+		f.compact(key, value)
 		return
 	}
+	if _, raw := value.(json.RawMessage); f.options.ExpandJSONBytes || (raw && f.options.ExpandRawMessage) {
+		if decoded, ok := f.expandJSONBytes(key, value); ok {
+			f.flatten(key, decoded, maxDepth)
+			return
+		}
+		if f.err != nil {
+			return
+		}
+	}
 
 	switch v := value.(type) {
 	case map[string]interface{}:
-		for key, val := range v {
-			flatten(prefix+key+delimiter, val, flattened, delimiter, maxDepth-1)
+		if f.options.TrimSpace {
+			var ok bool
+			if v, ok = f.trimMembers(key, v); !ok {
+				return
+			}
+		}
+		f.checkBreadth(key, v)
+		f.boundary(f.options.OnEnterObject, key)
+		for k, val := range v {
+			f.descend(f.childKey(key, k), SegmentKey, val, maxDepth-1)
 		}
+		f.boundary(f.options.OnLeaveObject, key)
 	case []interface{}:
-		for i, val := range v {
-			flatten(prefix+fmt.Sprintf("[%d]"+delimiter, i), val, flattened, delimiter, maxDepth-1)
+		if f.options.PreserveArrays {
+			f.store(key, v)
+			return
 		}
+		if f.options.ArrayPolicy != (ArrayPolicy{}) && f.applyArrayPolicy(key, v, maxDepth) {
+			return
+		}
+		if f.options.HoistCommonFields {
+			v = f.hoistCommon(key, v, maxDepth)
+		}
+		if f.options.CollapseUniformArrays && f.collapse(key, v) {
+			return
+		}
+		if f.options.ArrayKeyField != "" {
+			f.flattenKeyed(key, v, maxDepth)
+			return
+		}
+		f.flattenIndexed(key, v, maxDepth)
+	case []byte:
+		f.storeBytes(key, v, v)
 	default:
-		flattened[strings.TrimSuffix(prefix, delimiter)] = v
+		if s, ok := v.(fmt.Stringer); ok && f.options.UseStringer {
+			f.store(key, s.String())
+			return
+		}
+		// json.RawMessage and other named byte slices are leaves like []byte.
+		if b, ok := byteSlice(value); ok {
+			f.storeBytes(key, value, b)
+			return
+		}
+		// Pointers to maps, slices and arrays are walked through; nil ones are stored as nil leaves.
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Ptr {
+			switch rv.Type().Elem().Kind() {
+			case reflect.Map, reflect.Slice, reflect.Array:
+				if rv.IsNil() {
+					f.store(key, nil)
+				} else {
+					f.flatten(key, rv.Elem().Interface(), maxDepth)
+				}
+				return
+			}
+		}
+		// Typed slices such as []string are walked like []interface{}.
+		if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && !f.options.PreserveArrays {
+			if f.options.ArrayPolicy != (ArrayPolicy{}) {
+				elems := make([]interface{}, rv.Len())
+				for i := range elems {
+					elems[i] = rv.Index(i).Interface()
+				}
+				if f.applyArrayPolicy(key, elems, maxDepth) {
+					return
+				}
+			}
+			f.enterArray(key, rv.Len())
+			defer f.boundary(f.options.OnLeaveArray, key)
+			f.tagArray(key, rv.Len())
+			if f.options.EmitNumericAggregates {
+				f.aggregate(key, rv.Len(), func(i int) interface{} { return rv.Index(i).Interface() })
+			}
+			if f.options.IndexLabel != nil {
+				f.flattenLabeled(key, rv.Len(), func(i int) interface{} { return rv.Index(i).Interface() }, maxDepth)
+				return
+			}
+			for i := 0; i < rv.Len(); i++ {
+				f.descend(f.indexKey(key, i), SegmentIndex, rv.Index(i).Interface(), maxDepth-1)
+			}
+			return
+		}
+		if f.strict {
+			switch rv.Kind() {
+			case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+				f.fail(fmt.Errorf("%w: %s at %q", ErrUnsupportedKind, rv.Kind(), key))
+				return
+			}
+		}
+		f.store(key, v)
 	}
 }
 
-// UnflattenJSON unflattens a flattened JSON object into its original structure.
-//
-// Example:
-//
-//	flattened := map[string]interface{}{
-//		"address.city":  "New York",
-//		"address.state": "NY",
-//		"age":           30,
-//		"name":          "John",
-//	}
-//	options := DefaultOptions()
-//	unflattened, err := UnflattenJSON(flattened, options)
-//	if err != nil {
-//		fmt.Println("Error:", err)
-//		return
-//	}
-//	fmt.Println(unflattened)
-//
-// Output:
-//
-//	map[address:map[city:New York state:NY] age:30 name:John]
-func UnflattenJSON(flattened map[string]interface{}, options Options) (interface{}, error) {
-	result := make(map[string]interface{})
-	for key, value := range flattened {
-		setValue(result, strings.Split(key, options.KeyDelimiter), value)
+// storeBytes is a helper function that stores the byte slice leaf value, whose bytes are b,
+// encoded as Options.BytesEncoding asks.
+func (f *flattener) storeBytes(key string, value interface{}, b []byte) {
+	switch f.options.BytesEncoding {
+	case BytesBase64:
+		f.store(key, base64.StdEncoding.EncodeToString(b))
+	case BytesHex:
+		f.store(key, hex.EncodeToString(b))
+	default:
+		f.store(key, value)
 	}
-	return result, nil
 }
 
-// setValue is a helper function that sets a value in a nested map based on the given key path.
-func setValue(data map[string]interface{}, keys []string, value interface{}) {
-	lastKey := keys[len(keys)-1]
-	parent := data
-	for _, key := range keys[:len(keys)-1] {
-		if _, ok := parent[key]; !ok {
-			parent[key] = make(map[string]interface{})
+// byteSlice is a helper function that returns the bytes of value when it is a []byte,
+// json.RawMessage or any other slice whose elements are of kind uint8.
+func byteSlice(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case []byte:
+		return v, true
+	case json.RawMessage:
+		return v, true
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+		return rv.Bytes(), true
+	}
+	return nil, false
+}
+
+// expandJSONBytes is a helper function that decodes a byte slice leaf, such as []byte or
+// json.RawMessage, as JSON, reporting false for other values and for bytes that are not valid JSON.
+func (f *flattener) expandJSONBytes(key string, value interface{}) (interface{}, bool) {
+	data, ok := byteSlice(value)
+	if !ok {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if f.options.PreserveNumberText {
+		dec.UseNumber()
+	}
+	var decoded interface{}
+	err := dec.Decode(&decoded)
+	if err == nil && dec.More() {
+		err = ErrTrailingData
+	}
+	if err != nil {
+		if f.options.ExpandJSONBytesStrict {
+			f.fail(fmt.Errorf("%w at %q: %v", ErrInvalidJSONBytes, key, err))
 		}
-		parent = parent[key].(map[string]interface{})
+		return nil, false
 	}
-	parent[lastKey] = value
+	return decoded, true
+}
+
+// compact is a helper function that stores the subtree at key as a compact JSON string.
+func (f *flattener) compact(key string, value interface{}) {
+	if f.positions != nil {
+		value = stripPositions(value)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		f.fail(fmt.Errorf("goflat: encoding %q: %w", key, err))
+		return
+	}
+	f.store(key, strings.TrimSuffix(buf.String(), "\n"))
+}
+
+// descend is a helper function that flattens value one segment of the given kind below the
+// current one, tracking the kinds of the current path when FlattenJSONWithKinds needs them and the
+// keys walked when FlattenJSONWithPresence does.
+func (f *flattener) descend(key string, kind SegmentKind, value interface{}, maxDepth int) {
+	if f.presence != nil {
+		f.presence[key] = struct{}{}
+	}
+	if f.kinds == nil {
+		f.flatten(key, value, maxDepth)
+		return
+	}
+	f.path = append(f.path, kind)
+	f.flatten(key, value, maxDepth)
+	f.path = f.path[:len(f.path)-1]
+}
+
+// boundary is a helper function that calls a container boundary callback, when set, with the
+// segments of key.
+func (f *flattener) boundary(callback func(path []string), key string) {
+	if callback != nil {
+		callback(PathSplit(key, f.options))
+	}
+}
+
+// enterArray is a helper function that calls Options.OnEnterArray, when set, for the array of
+// length elements at key.
+func (f *flattener) enterArray(key string, length int) {
+	if f.options.OnEnterArray != nil {
+		f.options.OnEnterArray(PathSplit(key, f.options), length)
+	}
+}
+
+// recordKinds is a helper function that records the segment kinds of key, which has extra map key
+// segments below the current path, when FlattenJSONWithKinds needs them.
+func (f *flattener) recordKinds(key string, extra int) {
+	if f.kinds == nil {
+		return
+	}
+	kinds := make(SegmentKinds, len(f.path), len(f.path)+extra)
+	copy(kinds, f.path)
+	for i := 0; i < extra; i++ {
+		kinds = append(kinds, SegmentKey)
+	}
+	f.kinds[key] = kinds
+}
+
+// hoistCommon is a helper function that flattens the scalar fields shared by every element of arr
+// once under key, per Options.HoistCommonFields, and returns arr with those fields removed.
+func (f *flattener) hoistCommon(key string, arr []interface{}, maxDepth int) []interface{} {
+	if len(arr) < 2 {
+		return arr
+	}
+	elems := make([]map[string]interface{}, len(arr))
+	for i, val := range arr {
+		m, ok := unposition(val).(map[string]interface{})
+		if !ok {
+			return arr
+		}
+		elems[i] = m
+	}
+	var common []string
+	for _, field := range sortedKeys(elems[0]) {
+		first := unposition(elems[0][field])
+		shared := isScalar(first)
+		for _, m := range elems[1:] {
+			if val, ok := m[field]; !shared || !ok || !isScalar(unposition(val)) || unposition(val) != first {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			common = append(common, field)
+		}
+	}
+	if len(common) == 0 {
+		return arr
+	}
+	for _, field := range common {
+		f.descend(f.childKey(key, field), SegmentKey, elems[0][field], maxDepth-1)
+	}
+	stripped := make([]interface{}, len(elems))
+	for i, m := range elems {
+		rest := make(map[string]interface{}, len(m)-len(common))
+		for k, val := range m {
+			rest[k] = val
+		}
+		for _, field := range common {
+			delete(rest, field)
+		}
+		stripped[i] = rest
+	}
+	return stripped
+}
+
+// unposition is a helper function that removes a positioned wrapper from value, if any.
+func unposition(value interface{}) interface{} {
+	if p, ok := value.(positioned); ok {
+		return p.value
+	}
+	return value
+}
+
+// isScalar is a helper function that reports whether value is a comparable leaf rather than an
+// object or array.
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return value == nil || reflect.TypeOf(value).Comparable()
+}
+
+// flattenIndexed is a helper function that flattens the elements of arr under their indices, or
+// under the segments chosen by IndexLabel.
+func (f *flattener) flattenIndexed(key string, arr []interface{}, maxDepth int) {
+	f.enterArray(key, len(arr))
+	defer f.boundary(f.options.OnLeaveArray, key)
+	f.tagArray(key, len(arr))
+	if f.options.EmitNumericAggregates {
+		f.aggregate(key, len(arr), func(i int) interface{} { return arr[i] })
+	}
+	if f.options.IndexLabel != nil {
+		f.flattenLabeled(key, len(arr), func(i int) interface{} { return arr[i] }, maxDepth)
+		return
+	}
+	for i, val := range arr {
+		f.descend(f.indexKey(key, i), SegmentIndex, val, maxDepth-1)
+	}
+}
+
+// applyArrayPolicy is a helper function that flattens arr with the Options.ArrayPolicy mode for its
+// class, reporting false when the mode is ArrayDefault or does not apply to the class.
+func (f *flattener) applyArrayPolicy(key string, arr []interface{}, maxDepth int) bool {
+	policy := f.options.ArrayPolicy
+	objects, scalars := true, true
+	for _, val := range arr {
+		if p, ok := val.(positioned); ok {
+			val = p.value
+		}
+		switch val.(type) {
+		case map[string]interface{}:
+			scalars = false
+		case []interface{}:
+			objects, scalars = false, false
+		default:
+			objects = false
+		}
+	}
+	mode := policy.MixedArrays
+	switch {
+	case scalars:
+		mode = policy.ScalarArrays
+	case objects:
+		mode = policy.ObjectArrays
+	}
+
+	switch {
+	case mode == ArrayIndex:
+		f.flattenIndexed(key, arr, maxDepth)
+	case mode == ArrayKeyByField && objects && !scalars && f.options.ArrayKeyField != "":
+		f.flattenKeyed(key, arr, maxDepth)
+	case mode == ArrayPreserveWhole:
+		f.store(key, arr)
+	case mode == ArrayJoinScalars && scalars:
+		sep := policy.JoinSeparator
+		if sep == "" {
+			sep = ","
+		}
+		parts := make([]string, len(arr))
+		for i, val := range arr {
+			if p, ok := val.(positioned); ok {
+				val = p.value
+			}
+			if val != nil {
+				parts[i] = fmt.Sprint(val)
+			}
+		}
+		f.store(key, strings.Join(parts, sep))
+	default:
+		return false
+	}
+	return true
+}
+
+// flattenKeyed is a helper function that flattens the elements of arr under the value of their
+// ArrayKeyField, falling back to the index for elements without it.
+func (f *flattener) flattenKeyed(key string, arr []interface{}, maxDepth int) {
+	f.enterArray(key, len(arr))
+	defer f.boundary(f.options.OnLeaveArray, key)
+	seen := make(map[string]int, len(arr))
+	for i, val := range arr {
+		elemKey, kind := f.indexKey(key, i), SegmentIndex
+		if m, ok := unposition(val).(map[string]interface{}); ok {
+			if id, ok := m[f.options.ArrayKeyField]; ok {
+				elemKey, kind = f.childKey(key, fmt.Sprint(unposition(id))), SegmentKey
+			}
+		}
+		if j, ok := seen[elemKey]; ok {
+			f.fail(fmt.Errorf("%w: elements %d and %d of %q are both keyed %q", ErrKeyCollision, j, i, key, elemKey))
+			return
+		}
+		seen[elemKey] = i
+		f.descend(elemKey, kind, val, maxDepth-1)
+	}
+}
+
+// flattenLabeled is a helper function that flattens the n elements of an array, returned by elem,
+// under the segments chosen by IndexLabel.
+func (f *flattener) flattenLabeled(key string, n int, elem func(i int) interface{}, maxDepth int) {
+	seen := make(map[string]int, n)
+	for i := 0; i < n; i++ {
+		elemKey, kind := f.indexKey(key, i), SegmentIndex
+		if label := f.options.IndexLabel(i, n); label != "" {
+			elemKey, kind = f.childKey(key, label), SegmentKey
+		}
+		if j, ok := seen[elemKey]; ok {
+			f.fail(fmt.Errorf("%w: elements %d and %d of %q are both keyed %q", ErrKeyCollision, j, i, key, elemKey))
+			return
+		}
+		seen[elemKey] = i
+		f.descend(elemKey, kind, elem(i), maxDepth-1)
+	}
+}
+
+// store is a helper function that records a leaf value under key.
+func (f *flattener) store(key string, value interface{}) {
+	if f.positions != nil {
+		value = stripPositions(value)
+	}
+	if f.options.NonFiniteFloatPolicy != NonFiniteKeep {
+		var ok bool
+		if value, ok = f.finiteFloat(key, value); !ok {
+			return
+		}
+	}
+	if b, ok := value.(bool); ok && f.options.BoolFormat == BoolOneZero {
+		value = 0
+		if b {
+			value = 1
+		}
+	}
+	if s, ok := value.(string); ok && f.options.TrimSpace {
+		value = strings.TrimSpace(s)
+	}
+	if s, ok := value.(string); ok && f.options.ElideBase64 {
+		if n, ok := base64Size(s, f.options.Base64Threshold); ok {
+			value = fmt.Sprintf("<base64:%d bytes>", n)
+		}
+	}
+	if s, ok := value.(string); ok && f.options.MaxStringLength > 0 {
+		value = f.truncate(key, s)
+	}
+	if s, ok := value.(string); ok && f.options.InternStrings {
+		value = f.intern(s, value)
+	}
+	if f.options.Derive != nil {
+		f.derive(key, value)
+	}
+	if f.options.LeafVisitor != nil {
+		f.options.LeafVisitor(PathSplit(key, f.options), value)
+	}
+	if f.options.ExpandLeaf != nil {
+		if expanded := f.options.ExpandLeaf(key, value); expanded != nil {
+			for k, val := range expanded {
+				f.recordKinds(f.sidecarKey(key, k), 1)
+				f.put(f.sidecarKey(key, k), val)
+			}
+			return
+		}
+	}
+	f.recordKinds(key, 0)
+	f.put(key, value)
+}
+
+// truncate is a helper function that shortens s per Options.MaxStringLength, adding the length
+// sidecar entry of key when s is cut.
+func (f *flattener) truncate(key, s string) string {
+	limit := f.options.MaxStringLength
+	if len(s) <= limit {
+		return s
+	}
+	n := utf8.RuneCountInString(s)
+	if n <= limit {
+		return s
+	}
+	if f.options.StringLengthSidecar {
+		f.recordKinds(key+StringLengthSuffix, 0)
+		f.put(key+StringLengthSuffix, n)
+	}
+	cut := 0
+	for i := 0; i < limit; i++ {
+		_, size := utf8.DecodeRuneInString(s[cut:])
+		cut += size
+	}
+	return s[:cut] + "…"
+}
+
+// internTableSize is the number of distinct strings Options.InternStrings remembers per call.
+const internTableSize = 4096
+
+// intern is a helper function that returns the first leaf equal to the string s seen during the
+// walk, value itself when s is new. The first internTableSize distinct strings are remembered.
+func (f *flattener) intern(s string, value interface{}) interface{} {
+	if shared, ok := f.interned[s]; ok {
+		return shared
+	}
+	if f.interned == nil {
+		f.interned = make(map[string]interface{})
+	}
+	if len(f.interned) < internTableSize {
+		f.interned[s] = value
+	}
+	return value
+}
+
+// derive is a helper function that collects the entries Options.Derive returns for a leaf.
+func (f *flattener) derive(key string, value interface{}) {
+	for k, val := range f.options.Derive(key, value) {
+		if old, ok := f.derived[k]; ok {
+			if !f.options.DeriveOverwrite {
+				f.fail(fmt.Errorf("%w: derived key %q", ErrKeyCollision, k))
+				return
+			}
+			f.lose(k, old)
+		}
+		if f.derived == nil {
+			f.derived = make(map[string]interface{})
+		}
+		f.derived[k] = val
+	}
+}
+
+// finiteFloat is a helper function that applies NonFiniteFloatPolicy to a NaN or infinite float
+// value, reporting false when the leaf is rejected.
+func (f *flattener) finiteFloat(key string, value interface{}) (interface{}, bool) {
+	var x float64
+	switch v := value.(type) {
+	case float64:
+		x = v
+	case float32:
+		x = float64(v)
+	default:
+		return value, true
+	}
+	if !math.IsNaN(x) && !math.IsInf(x, 0) {
+		return value, true
+	}
+	switch f.options.NonFiniteFloatPolicy {
+	case NonFiniteError:
+		f.fail(fmt.Errorf("%w: %v at %q", ErrNonFiniteFloat, x, key))
+		return nil, false
+	case NonFiniteNull:
+		return nil, true
+	case NonFiniteString:
+		return strconv.FormatFloat(x, 'g', -1, 64), true
+	}
+	return value, true
+}
+
+// base64Size is a helper function that reports the decoded size of s when s is at least threshold
+// characters long and holds base64 data, optionally as the payload of a data URI.
+func base64Size(s string, threshold int) (int, bool) {
+	if threshold <= 0 {
+		threshold = defaultBase64Threshold
+	}
+	if len(s) < threshold {
+		return 0, false
+	}
+	if strings.HasPrefix(s, "data:") {
+		i := strings.Index(s, ";base64,")
+		if i < 0 {
+			return 0, false
+		}
+		s = s[i+len(";base64,"):]
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if decoded, err := enc.DecodeString(s); err == nil {
+			return len(decoded), true
+		}
+	}
+	return 0, false
+}
+
+// put is a helper function that writes a finished entry to the output map, or hands it to the
+// sink when one is set. Nothing is written once the walk has failed.
+func (f *flattener) put(key string, value interface{}) {
+	if f.err != nil {
+		return
+	}
+	if f.targets != nil && !f.targets[key] {
+		return
+	}
+	if f.options.TypedValues {
+		value = newTypedValue(value)
+	}
+	f.keys++
+	if f.options.MaxKeys > 0 && f.keys > f.options.MaxKeys {
+		f.fail(fmt.Errorf("%w: more than %d keys", ErrTooManyKeys, f.options.MaxKeys))
+		return
+	}
+	if f.sink != nil {
+		if err := f.sink(key, value); err != nil {
+			f.fail(err)
+		}
+		return
+	}
+	if old, ok := f.flattened[key]; ok {
+		f.lose(key, old)
+	}
+	f.flattened[key] = value
+}
+
+// lose is a helper function that records value as overwritten under key when losses are tracked.
+func (f *flattener) lose(key string, value interface{}) {
+	if f.losses != nil {
+		f.losses[key] = append(f.losses[key], value)
+	}
+}
+
+// childKey is a helper function that returns the key of the map member k under key.
+func (f *flattener) childKey(key string, k string) string {
+	if f.options.KeyBuilder != nil {
+		return f.options.KeyBuilder.Child(key, f.normalizeKey(k))
+	}
+	if f.keyBuf != nil {
+		return f.bufferedChild(key, k)
+	}
+	if f.prefixes == nil {
+		return key + f.options.KeyDelimiter + f.mapKey(k)
+	}
+	id := prefixKey{parent: key, member: k, index: -1}
+	if cached, ok := f.prefixes.get(id); ok {
+		return cached
+	}
+	return f.remember(id, key+f.options.KeyDelimiter+f.mapKey(k))
+}
+
+// rootKey is a helper function that returns the key of the top-level member k.
+func (f *flattener) rootKey(k string) string {
+	if f.options.KeyBuilder != nil {
+		return f.options.KeyBuilder.Child("", f.normalizeKey(k))
+	}
+	if f.prefixes == nil {
+		return f.joinRoot(k)
+	}
+	id := prefixKey{member: k, index: -1, root: true}
+	if cached, ok := f.prefixes.get(id); ok {
+		return cached
+	}
+	return f.remember(id, f.joinRoot(k))
+}
+
+// joinRoot is a helper function that builds the key of the top-level member k.
+func (f *flattener) joinRoot(k string) string {
+	if f.options.JSONPointer {
+		return f.options.KeyDelimiter + f.mapKey(k)
+	}
+	return f.mapKey(k)
+}
+
+// remember is a helper function that caches key as the key built for id and returns it. Keys
+// built while an error was raised are not cached, so the error is raised again on the next build.
+func (f *flattener) remember(id prefixKey, key string) string {
+	if f.err == nil {
+		f.prefixes.put(id, key)
+	}
+	return key
+}
+
+// mapKey is a helper function that normalizes and escapes a single map key segment.
+func (f *flattener) mapKey(k string) string {
+	return escapeSegment(f.normalizeKey(k), f.options)
+}
+
+// normalizeKey is a helper function that applies the key rewriting options to a single map key.
+func (f *flattener) normalizeKey(k string) string {
+	if f.options.NormalizeUnicodeKeys {
+		k = norm.NFC.String(k)
+	}
+	switch f.options.KeyCase {
+	case CaseLower:
+		k = strings.ToLower(k)
+	case CaseUpper:
+		k = strings.ToUpper(k)
+	}
+	if k == "" && f.options.EmptyKeyReplacement != "" {
+		k = f.options.EmptyKeyReplacement
+	}
+	if f.options.MongoKeys && (k == "" || strings.Contains(k, ".") || strings.HasPrefix(k, "$")) {
+		f.fail(fmt.Errorf("%w: %q", ErrInvalidFieldName, k))
+	}
+	return k
+}
+
+// sidecarKey is a helper function that returns the key of the sidecar entry name under key, such
+// as an ArrayMarkerKey entry. name is used as it is.
+func (f *flattener) sidecarKey(key, name string) string {
+	if f.options.KeyBuilder != nil {
+		return f.options.KeyBuilder.Child(key, name)
+	}
+	return key + f.options.KeyDelimiter + name
+}
+
+// indexKey is a helper function that returns the key of the array element i under key.
+func (f *flattener) indexKey(key string, i int) string {
+	if f.options.KeyBuilder != nil {
+		return f.options.KeyBuilder.Index(key, i)
+	}
+	// Base-36 indices are recorded as they are built, so they are never cached.
+	if f.prefixes == nil || f.options.MaxIndexDigits > 0 {
+		return f.joinIndex(key, i)
+	}
+	id := prefixKey{parent: key, index: i}
+	if cached, ok := f.prefixes.get(id); ok {
+		return cached
+	}
+	return f.remember(id, f.joinIndex(key, i))
+}
+
+// joinIndex is a helper function that builds the key of the array element i under key.
+func (f *flattener) joinIndex(key string, i int) string {
+	if f.keyBuf != nil && f.options.IndexBucketSize <= 0 {
+		return f.bufferedIndex(key, i)
+	}
+	if size := f.options.IndexBucketSize; size > 0 {
+		key = key + f.options.KeyDelimiter + bucketPrefix + strconv.Itoa(i/size)
+		i %= size
+	}
+	if f.options.ArrayNotation == NotationBracket {
+		return key + "[" + f.formatIndex(key, i) + "]"
+	}
+	return key + indexDelimiter(f.options) + f.formatIndex(key, i)
+}
+
+// formatIndex is a helper function that renders an array index, zero-padding it to PadIndexWidth.
+func (f *flattener) formatIndex(key string, i int) string {
+	index := strconv.Itoa(i)
+	if f.options.MaxIndexDigits > 0 && len(index) > f.options.MaxIndexDigits {
+		return f.hashIndex(key, i)
+	}
+	width := f.options.PadIndexWidth
+	if width <= 0 || len(index) == width {
+		return index
+	}
+	if len(index) > width {
+		if f.options.PadIndexStrict {
+			f.fail(fmt.Errorf("%w: index %d under %q", ErrIndexTooWide, i, key))
+		}
+		return index
+	}
+	return strings.Repeat("0", width-len(index)) + index
+}
+
+// collapse is a helper function that stores a uniform array of objects column-style.
+// It reports false, storing nothing, when the elements are not uniform.
+func (f *flattener) collapse(key string, arr []interface{}) bool {
+	if len(arr) == 0 {
+		return false
+	}
+	first, ok := unposition(arr[0]).(map[string]interface{})
+	if !ok || len(first) == 0 {
+		return false
+	}
+	for _, elem := range arr {
+		m, ok := unposition(elem).(map[string]interface{})
+		if !ok || len(m) != len(first) {
+			return false
+		}
+		for k, val := range m {
+			if _, ok := first[k]; !ok || isContainer(unposition(val)) {
+				return false
+			}
+		}
+	}
+
+	for k := range first {
+		column := make([]interface{}, len(arr))
+		for i, elem := range arr {
+			column[i] = unposition(elem).(map[string]interface{})[k]
+		}
+		f.store(f.childKey(key, k), column)
+	}
+	return true
+}
+
+// tagArray is a helper function that records the sidecar keys of the array of length n at key:
+// the array marker when TagArrays is set and the length when EmitArrayLength is set.
+func (f *flattener) tagArray(key string, n int) {
+	if f.options.TagArrays {
+		f.recordKinds(f.sidecarKey(key, ArrayMarkerKey), 1)
+		f.put(f.sidecarKey(key, ArrayMarkerKey), ArrayMarkerValue)
+	}
+	if f.options.EmitArrayLength {
+		f.recordKinds(f.sidecarKey(key, ArrayLengthKey), 1)
+		f.put(f.sidecarKey(key, ArrayLengthKey), n)
+	}
+}
+
+// aggregate is a helper function that adds the Options.EmitNumericAggregates sidecar keys of the
+// n elements of an array, returned by elem, when they are all numbers.
+func (f *flattener) aggregate(key string, n int, elem func(i int) interface{}) {
+	if n == 0 {
+		return
+	}
+	var min, max, sum float64
+	for i := 0; i < n; i++ {
+		x, ok := numericValue(unposition(elem(i)))
+		if !ok {
+			return
+		}
+		if i == 0 || x < min {
+			min = x
+		}
+		if i == 0 || x > max {
+			max = x
+		}
+		sum += x
+	}
+	stats := []struct {
+		name  string
+		value float64
+	}{{"min", min}, {"max", max}, {"sum", sum}, {"avg", sum / float64(n)}}
+	for _, stat := range stats {
+		f.recordKinds(f.sidecarKey(key, stat.name), 1)
+		f.put(f.sidecarKey(key, stat.name), stat.value)
+	}
+}
+
+// UnflattenJSON unflattens a flattened JSON object into its original structure.
+// Nested nodes whose keys are exactly the indices 0..n-1 are rebuilt as []interface{},
+// so arrays of arrays, and objects nested inside them, round-trip at any depth. Only empty
+// containers and, unless ArraysRequireBrackets is set, maps with index-like keys do not.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{
+//		"address.city":  "New York",
+//		"address.state": "NY",
+//		"age":           30,
+//		"name":          "John",
+//	}
+//	options := DefaultOptions()
+//	unflattened, err := UnflattenJSON(flattened, options)
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(unflattened)
+//
+// Output:
+//
+//	map[address:map[city:New York state:NY] age:30 name:John]
+func UnflattenJSON(flattened map[string]interface{}, options Options) (interface{}, error) {
+	u := newUnflattener(options)
+	for key, value := range flattened {
+		if err := u.add(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if !u.needsPromotion() {
+		return u.result, nil
+	}
+	return u.promoteMembers(u.result), nil
+}
+
+// UnflattenJSONWithSkipped unflattens like UnflattenJSON and also returns, in sorted order, the
+// keys skipped as malformed when options.LenientUnflatten is set. Keys are added in sorted order,
+// so which of two conflicting keys is skipped does not depend on map iteration order.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"name": "John", "address..city": "New York", "age.": 30}
+//	options := DefaultOptions()
+//	options.LenientUnflatten = true
+//	unflattened, skipped, err := UnflattenJSONWithSkipped(flattened, options)
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(unflattened, skipped)
+//
+// Output:
+//
+//	map[name:John] [address..city age.]
+func UnflattenJSONWithSkipped(flattened map[string]interface{}, options Options) (interface{}, []string, error) {
+	u := newUnflattener(options)
+	for _, key := range sortedKeys(flattened) {
+		if err := u.add(key, flattened[key]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !u.needsPromotion() {
+		return u.result, u.skipped, nil
+	}
+	return u.promoteMembers(u.result), u.skipped, nil
+}
+
+// UnflattenPrefix unflattens only the subtree stored under prefix, skipping every other key.
+// The prefix is a flattened key written with the same options, e.g. "address" or "/address" with
+// JSON Pointers. A scalar stored directly under prefix is returned as it is. The subtree is a
+// node below the top level, so unlike the result of UnflattenJSON it is rebuilt as an array when
+// its keys are indices.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{
+//		"address.city":  "New York",
+//		"address.state": "NY",
+//		"name":          "John",
+//	}
+//	address, err := UnflattenPrefix(flattened, "address", DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(address)
+//
+// Output:
+//
+//	map[city:New York state:NY]
+func UnflattenPrefix(flattened map[string]interface{}, prefix string, options Options) (interface{}, error) {
+	if value, ok := flattened[prefix]; ok {
+		return value, nil
+	}
+	u := newUnflattener(options)
+	for key, value := range flattened {
+		rest, ok := underPrefix(key, prefix, options)
+		if !ok {
+			continue
+		}
+		if err := u.add(rest, value); err != nil {
+			return nil, err
+		}
+	}
+	if !u.needsPromotion() {
+		return u.result, nil
+	}
+	return u.promoteArrays(u.result, nil), nil
+}
+
+// SubtreeKeys returns the entries of flattened that make up the subtree at prefix: every key below
+// it, and prefix itself when it holds a leaf. It selects what a receiver needs to rebuild that
+// subtree, for example with UnflattenPrefix. Keys merely sharing the prefix text, such as
+// "addressBook" for "address", are not part of the subtree.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"address.city": "New York", "addressBook.0": "Ann", "name": "John"}
+//	fmt.Println(SubtreeKeys(flattened, "address", DefaultOptions()))
+//
+// Output:
+//
+//	map[address.city:New York]
+func SubtreeKeys(flattened map[string]interface{}, prefix string, options Options) map[string]interface{} {
+	subtree := make(map[string]interface{})
+	for key, value := range flattened {
+		if _, ok := underPrefix(key, prefix, options); ok || key == prefix {
+			subtree[key] = value
+		}
+	}
+	return subtree
+}
+
+// underPrefix is a helper function that returns the part of key below prefix, reporting false for
+// keys outside the subtree. With JSON Pointers the remainder keeps its leading delimiter.
+func underPrefix(key, prefix string, options Options) (string, bool) {
+	rest := strings.TrimPrefix(key, prefix)
+	if len(rest) == len(key) {
+		return "", false
+	}
+	if parsesBrackets(options) && strings.HasPrefix(rest, "[") {
+		return rest, true
+	}
+	if !strings.HasPrefix(rest, options.KeyDelimiter) {
+		return "", false
+	}
+	if options.JSONPointer {
+		return rest, true
+	}
+	return rest[len(options.KeyDelimiter):], true
+}
+
+// bucketPrefix starts the bucket segment written when Options.IndexBucketSize is set.
+const bucketPrefix = "bucket_"
+
+// splitKeys is a helper function that splits a flattened key into plain map keys, turning
+// bracketed and bucketed indices into their decimal form.
+func splitKeys(key string, options Options) []string {
+	segments := splitSegments(key, options)
+	keys := make([]string, len(segments))
+	for i, seg := range segments {
+		keys[i] = seg.key
+	}
+	return keys
+}
+
+// splitSegments is a helper function that splits a flattened key like splitKeys but keeps
+// whether each segment was bracketed.
+func splitSegments(key string, options Options) []segment {
+	segments := splitKey(key, options)
+	if options.EmptyKeyReplacement != "" {
+		for i, seg := range segments {
+			if !seg.bracketed && seg.key == options.EmptyKeyReplacement {
+				segments[i].key = ""
+			}
+		}
+	}
+	if options.PadIndexWidth > 0 {
+		for i, seg := range segments {
+			if len(seg.key) >= options.PadIndexWidth && isDigits(seg.key) {
+				segments[i].key = strings.TrimLeft(seg.key[:len(seg.key)-1], "0") + seg.key[len(seg.key)-1:]
+			}
+		}
+	}
+	if options.IndexBucketSize <= 0 {
+		return segments
+	}
+	merged := make([]segment, 0, len(segments))
+	for i := 0; i < len(segments); i++ {
+		seg := segments[i]
+		if i+1 < len(segments) && strings.HasPrefix(seg.key, bucketPrefix) {
+			bucket, okBucket := parseIndex(strings.TrimPrefix(seg.key, bucketPrefix))
+			index, okIndex := parseIndex(segments[i+1].key)
+			if okBucket && okIndex {
+				seg = segment{key: strconv.Itoa(bucket*options.IndexBucketSize + index), bracketed: segments[i+1].bracketed}
+				i++
+			}
+		}
+		merged = append(merged, seg)
+	}
+	return merged
+}
+
+// pathKey is a helper function that turns a key path into a string usable as a map key.
+func pathKey(keys []string) string {
+	return strings.Join(keys, "\x00")
+}
+
+// unflattener holds the state shared by a single unflatten call.
+type unflattener struct {
+	options Options
+	result  map[string]interface{}
+	arrays  map[string]bool // Keys of nodes marked as arrays by TagArrays or EmitArrayLength
+	indexed bool            // Whether any segment added so far looks like an array index
+	skipped []string        // Keys skipped as malformed when LenientUnflatten is set
+	exact   bool            // Whether arrays come from segment kinds; see UnflattenJSONWithKinds
+}
+
+// newUnflattener is a helper function that creates an unflattener with an empty result.
+func newUnflattener(options Options) *unflattener {
+	return &unflattener{options: options, result: make(map[string]interface{})}
+}
+
+// add is a helper function that sets a single flattened key in the nested result.
+func (u *unflattener) add(key string, value interface{}) error {
+	if u.options.TypedValues {
+		value = untypedValue(value)
+	}
+	segments := splitSegments(key, u.options)
+	keys := make([]string, len(segments))
+	for i, seg := range segments {
+		keys[i] = seg.key
+		if seg.bracketed && u.options.ArraysRequireBrackets {
+			u.recordArray(keys[:i])
+		}
+		if !u.indexed {
+			_, u.indexed = parseIndex(seg.key)
+		}
+	}
+	if u.options.LenientUnflatten && hasEmptySegment(keys) {
+		u.skipped = append(u.skipped, key)
+		return nil
+	}
+	if u.options.TagArrays && keys[len(keys)-1] == ArrayMarkerKey && value == ArrayMarkerValue {
+		u.markArray(u.result, keys[:len(keys)-1])
+		return nil
+	}
+	if u.options.EmitArrayLength && len(keys) > 1 && keys[len(keys)-1] == ArrayLengthKey {
+		u.markArray(u.result, keys[:len(keys)-1])
+		u.indexed = true
+		return nil
+	}
+	if err := setValue(u.result, keys, value); err != nil {
+		if u.options.LenientUnflatten {
+			u.skipped = append(u.skipped, key)
+			return nil
+		}
+		return fmt.Errorf("%w: %q", err, key)
+	}
+	return nil
+}
+
+// hasEmptySegment is a helper function that reports whether any of keys is empty.
+func hasEmptySegment(keys []string) bool {
+	for _, key := range keys {
+		if key == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// needsPromotion is a helper function that reports whether any node may have to be rebuilt as
+// an array, so that purely map-structured input can skip the promotion pass.
+func (u *unflattener) needsPromotion() bool {
+	if u.recordedOnly() {
+		return len(u.arrays) > 0
+	}
+	return u.indexed
+}
+
+// recordedOnly is a helper function that reports whether only nodes recorded as arrays, by
+// TagArrays markers, bracketed indices or segment kinds, are rebuilt as arrays.
+func (u *unflattener) recordedOnly() bool {
+	return u.options.TagArrays || u.options.ArraysRequireBrackets || u.exact
+}
+
+// recordArray is a helper function that records the node at keys as an array.
+func (u *unflattener) recordArray(keys []string) {
+	if u.arrays == nil {
+		u.arrays = make(map[string]bool)
+	}
+	u.arrays[pathKey(keys)] = true
+}
+
+// markArray is a helper function that records keys as an array node, creating it so that
+// empty arrays survive the round trip.
+func (u *unflattener) markArray(data map[string]interface{}, keys []string) {
+	u.recordArray(keys)
+
+	parent := data
+	for _, key := range keys {
+		if _, ok := parent[key]; !ok {
+			parent[key] = make(map[string]interface{})
+		}
+		next, ok := parent[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		parent = next
+	}
+}
+
+// setValue is a helper function that sets a value in a nested map based on the given key path.
+// It returns ErrKeyConflict when the path runs through a leaf or would replace a nested object.
+func setValue(data map[string]interface{}, keys []string, value interface{}) error {
+	lastKey := keys[len(keys)-1]
+	parent := data
+	for _, key := range keys[:len(keys)-1] {
+		if _, ok := parent[key]; !ok {
+			parent[key] = make(map[string]interface{})
+		}
+		next, ok := parent[key].(map[string]interface{})
+		if !ok {
+			return ErrKeyConflict
+		}
+		parent = next
+	}
+	if _, ok := parent[lastKey].(map[string]interface{}); ok {
+		return ErrKeyConflict
+	}
+	parent[lastKey] = value
+	return nil
+}
+
+// promoteMembers is a helper function that promotes the nodes below root like promoteArrays while
+// keeping root itself a map, even when its keys are all indices.
+func (u *unflattener) promoteMembers(root map[string]interface{}) map[string]interface{} {
+	for k, val := range root {
+		root[k] = u.promoteArrays(val, []string{k})
+	}
+	return root
+}
+
+// promoteArrays is a helper function that recursively replaces maps keyed by contiguous
+// indices with slices. Children are promoted first so inner arrays become slices too.
+// With TagArrays set only the marked nodes are considered.
+func (u *unflattener) promoteArrays(value interface{}, keys []string) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	for k, val := range m {
+		m[k] = u.promoteArrays(val, append(keys[:len(keys):len(keys)], k))
+	}
+
+	members := make([]string, 0, len(m))
+	for k := range m {
+		members = append(members, k)
+	}
+	if !u.isArray(keys, members) {
+		return m
+	}
+
+	arr := make([]interface{}, len(m))
+	for k, val := range m {
+		i, _ := parseIndex(k)
+		arr[i] = val
+	}
+	return arr
+}
+
+// isArray is a helper function that decides whether the node at keys, whose member keys are
+// members, is rebuilt as an array. Members must be exactly the indices 0..n-1.
+func (u *unflattener) isArray(keys []string, members []string) bool {
+	if u.recordedOnly() {
+		if !u.arrays[pathKey(keys)] {
+			return false
+		}
+		if len(members) == 0 {
+			return true
+		}
+	} else if len(members) < u.options.MinArrayKeys {
+		return false
+	}
+	if len(members) == 0 {
+		// Only nodes known to be arrays, such as those with a length key, can be empty arrays.
+		return u.arrays[pathKey(keys)]
+	}
+
+	seen := make([]bool, len(members))
+	for _, k := range members {
+		i, ok := parseIndex(k)
+		if !ok || i >= len(members) || seen[i] {
+			return false
+		}
+		seen[i] = true
+	}
+	return true
+}
+
+// parseIndex is a helper function that reports whether key is a canonical, non-negative array index.
+func parseIndex(key string) (int, bool) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || strconv.Itoa(i) != key {
+		return 0, false
+	}
+	return i, true
 }
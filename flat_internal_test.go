@@ -0,0 +1,71 @@
+package goflat
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// arrayFreeInput builds a flattened, purely map-structured document with n leaves.
+func arrayFreeInput(n int) map[string]interface{} {
+	flattened := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		flattened["section"+strconv.Itoa(i%10)+".group"+strconv.Itoa(i%7)+".key"+strconv.Itoa(i)] = i
+	}
+	return flattened
+}
+
+func TestUnflattenJSONFastPath(t *testing.T) {
+	// Test case 1: Skipping the promotion pass gives the same result as running it
+	flattened := arrayFreeInput(200)
+	options := DefaultOptions()
+	result, err := UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf("Error unflattening JSON: %+v", err)
+	}
+
+	u := newUnflattener(options)
+	for key, value := range flattened {
+		if err := u.add(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if u.needsPromotion() {
+		t.Errorf("Array-free input should not need the promotion pass")
+	}
+	if !reflect.DeepEqual(result, u.promoteMembers(u.result)) {
+		t.Errorf("Fast path result differs from the promoted result")
+	}
+
+	// Test case 2: A numeric segment enables the pass
+	if err := u.add("list.0", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if !u.needsPromotion() {
+		t.Errorf("Input with an index segment should need the promotion pass")
+	}
+}
+
+func BenchmarkUnflattenJSONArrayFree(b *testing.B) {
+	flattened := arrayFreeInput(1000)
+	options := DefaultOptions()
+
+	b.Run("fast path", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := UnflattenJSON(flattened, options); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("promotion pass", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			u := newUnflattener(options)
+			for key, value := range flattened {
+				if err := u.add(key, value); err != nil {
+					b.Fatal(err)
+				}
+			}
+			u.promoteMembers(u.result)
+		}
+	})
+}
@@ -1,8 +1,20 @@
 package goflat_test
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
 	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 
 	goflat "github.com/brian-s-side-project/go-flat"
 )
@@ -29,7 +41,7 @@ func TestFlattenJSON(t *testing.T) {
 	data := []byte(`{"name": "John", "age": 30}`)
 	expected := map[string]interface{}{
 		"name": "John",
-		"age":  30,
+		"age":  float64(30),
 	}
 	options := goflat.DefaultOptions()
 	result, err := goflat.FlattenJSON(data, options)
@@ -46,7 +58,7 @@ func TestFlattenJSON(t *testing.T) {
 
 	expected = map[string]interface{}{
 		"name":           "John",
-		"age":            30,
+		"age":            float64(30),
 		addressStreetKey: addressStreet,
 		addressCityKey:   addressCity,
 		hobbies0Key:      hobbies0,
@@ -226,3 +238,1931 @@ func TestUnflattenJSON(t *testing.T) {
 		t.Errorf(errorUnflattenedJSONMismatch)
 	}
 }
+
+func TestUnflattenJSONNestedArrays(t *testing.T) {
+	// Test case 1: Round-tripping a 2D array
+	data := []byte(`{"matrix": [[1, 2], [3, 4]]}`)
+	options := goflat.DefaultOptions()
+	assertRoundTrip(t, data, options)
+
+	// Test case 2: Round-tripping a 3D array
+	data = []byte(`{"cube": [[[1, 2], [3, 4]], [[5, 6], [7, 8]]]}`)
+	assertRoundTrip(t, data, options)
+
+	// Test case 3: Round-tripping an array of objects containing arrays
+	data = []byte(`{"rows": [{"id": 1, "tags": ["a", "b"]}, {"id": 2, "tags": [["c"], ["d", "e"]]}]}`)
+	assertRoundTrip(t, data, options)
+
+	// Test case 4: Keys that are not contiguous from zero stay a map
+	flattened := map[string]interface{}{
+		"a.0": "x",
+		"a.2": "y",
+	}
+	expected := map[string]interface{}{
+		"a": map[string]interface{}{
+			"0": "x",
+			"2": "y",
+		},
+	}
+	result, err := goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 5: A top-level object with index-like keys stays a map
+	data = []byte(`{"0": "a", "1": {"name": "b"}}`)
+	assertRoundTrip(t, data, options)
+	flattened = map[string]interface{}{"0": "a", "1.0": "b"}
+	expected = map[string]interface{}{"0": "a", "1": []interface{}{"b"}}
+	result, err = goflat.UnflattenJSON(flattened, options)
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("Unexpected result %v (%v)", result, err)
+	}
+	result, _, err = goflat.UnflattenJSONWithSkipped(flattened, options)
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("Unexpected result %v (%v)", result, err)
+	}
+	result, err = goflat.UnflattenSorted([]string{"0", "1.0"}, []interface{}{"a", "b"}, options)
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("Unexpected result %v (%v)", result, err)
+	}
+	u := goflat.NewUnflattener(options)
+	for key, value := range flattened {
+		if err := u.Add(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if result, err = u.Result(); err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("Unexpected result %v (%v)", result, err)
+	}
+}
+
+func TestUnflattenJSONMixedNesting(t *testing.T) {
+	// Test case 1: Objects nested inside arrays of arrays
+	options := goflat.DefaultOptions()
+	assertRoundTrip(t, []byte(`{"x": [[{"a": 1}]]}`), options)
+	assertRoundTrip(t, []byte(`{"x": [[[{"a": [[1, {"b": [[2]]}]]}]], {"c": [[{"d": 3}, [4]]]}]}`), options)
+
+	// Test case 2: Random interleavings of arrays and objects up to depth 6
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		document := map[string]interface{}{"root": randomNode(rng, 6, false)}
+		data, err := json.Marshal(document)
+		if err != nil {
+			t.Fatalf("Error encoding test input: %+v", err)
+		}
+		assertRoundTrip(t, data, options)
+	}
+
+	// Test case 3: With bracketed indices, numeric object keys stay apart from array indices
+	options.ArrayNotation = goflat.NotationBracket
+	options.ArraysRequireBrackets = true
+	assertRoundTrip(t, []byte(`{"x": [[{"0": 1}], {"1": [[2]]}]}`), options)
+	for i := 0; i < 500; i++ {
+		document := map[string]interface{}{"root": randomNode(rng, 6, true)}
+		data, err := json.Marshal(document)
+		if err != nil {
+			t.Fatalf("Error encoding test input: %+v", err)
+		}
+		assertRoundTrip(t, data, options)
+	}
+}
+
+// randomNode builds a random non-empty tree of arrays, objects and scalars at most depth levels
+// deep. Object keys are letters, or also digits when numericKeys is set.
+func randomNode(rng *rand.Rand, depth int, numericKeys bool) interface{} {
+	if depth == 0 || rng.Intn(4) == 0 {
+		switch rng.Intn(4) {
+		case 0:
+			return "s" + strconv.Itoa(rng.Intn(100))
+		case 1:
+			return float64(rng.Intn(100))
+		case 2:
+			return rng.Intn(2) == 0
+		}
+		return nil
+	}
+	n := 1 + rng.Intn(3)
+	if rng.Intn(2) == 0 {
+		array := make([]interface{}, n)
+		for i := range array {
+			array[i] = randomNode(rng, depth-1, numericKeys)
+		}
+		return array
+	}
+	object := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key := string(rune('a' + rng.Intn(26)))
+		if numericKeys && rng.Intn(2) == 0 {
+			key = strconv.Itoa(rng.Intn(3))
+		}
+		object[key] = randomNode(rng, depth-1, numericKeys)
+	}
+	return object
+}
+
+// assertRoundTrip flattens data, unflattens the result and compares it against the decoded original.
+func assertRoundTrip(t *testing.T, data []byte, options goflat.Options) {
+	t.Helper()
+	var expected map[string]interface{}
+	if err := json.Unmarshal(data, &expected); err != nil {
+		t.Fatalf("Error decoding test input: %+v", err)
+	}
+	flattened, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Fatalf(errorFlatteningJSON, err)
+	}
+	result, err := goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Fatalf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Round trip mismatch: got %v, want %v", result, expected)
+	}
+}
+
+func TestTagArrays(t *testing.T) {
+	// Test case 1: Flattening records a marker next to each array
+	data := []byte(`{"hobbies": ["reading", "gaming"], "codes": {"0": "a", "1": "b"}}`)
+	options := goflat.DefaultOptions()
+	options.TagArrays = true
+	expected := map[string]interface{}{
+		hobbies0Key:     hobbies0,
+		hobbies1Key:     hobbies1,
+		"hobbies.#type": "array",
+		"codes.0":       "a",
+		"codes.1":       "b",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Unflattening rebuilds marked arrays and keeps numeric-keyed maps as maps
+	unflattened, err := goflat.UnflattenJSON(result, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	expectedNested := map[string]interface{}{
+		"hobbies": []interface{}{hobbies0, hobbies1},
+		"codes": map[string]interface{}{
+			"0": "a",
+			"1": "b",
+		},
+	}
+	if !reflect.DeepEqual(unflattened, expectedNested) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 3: Nested and empty arrays survive the round trip
+	data = []byte(`{"matrix": [[1, 2], []], "empty": []}`)
+	assertRoundTrip(t, data, options)
+}
+
+func TestKeyCase(t *testing.T) {
+	// Test case 1: Lowercasing map keys leaves indices untouched
+	data := []byte(`{"Name": "John", "Address": {"City": "New York"}, "Hobbies": ["reading", {"Kind": "gaming"}]}`)
+	options := goflat.DefaultOptions()
+	options.KeyCase = goflat.CaseLower
+	expected := map[string]interface{}{
+		"name":           "John",
+		addressCityKey:   addressCity,
+		hobbies0Key:      hobbies0,
+		"hobbies.1.kind": hobbies1,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Uppercasing map keys with bracket notation
+	options.KeyCase = goflat.CaseUpper
+	options.ArrayNotation = goflat.NotationBracket
+	expected = map[string]interface{}{
+		"NAME":            "John",
+		"ADDRESS.CITY":    addressCity,
+		"HOBBIES[0]":      hobbies0,
+		"HOBBIES[1].KIND": hobbies1,
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestUnflattenJSONConflict(t *testing.T) {
+	// Test case 1: A key that is both a leaf and a parent is reported as a conflict
+	flattened := map[string]interface{}{
+		"a":   1,
+		"a.b": 2,
+	}
+	options := goflat.DefaultOptions()
+	_, err := goflat.UnflattenJSON(flattened, options)
+	if !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+}
+
+func TestCollapseUniformArrays(t *testing.T) {
+	// Test case 1: An array of objects with the same scalar fields collapses into columns
+	data := []byte(`{"items": [{"name": "a", "qty": 1}, {"name": "b", "qty": 2}]}`)
+	options := goflat.DefaultOptions()
+	options.CollapseUniformArrays = true
+	expected := map[string]interface{}{
+		"items.name": []interface{}{"a", "b"},
+		"items.qty":  []interface{}{float64(1), float64(2)},
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Non-uniform arrays are flattened by index
+	data = []byte(`{"items": [{"name": "a"}, {"name": "b", "qty": 2}], "nested": [{"tags": ["x"]}]}`)
+	expected = map[string]interface{}{
+		"items.0.name":    "a",
+		"items.1.name":    "b",
+		"items.1.qty":     float64(2),
+		"nested.0.tags.0": "x",
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 3: Arrays collapse the same way when positions or order are tracked
+	data = []byte(`{"items": [{"name": "a", "qty": 1}, {"name": "b", "qty": 2}], "nested": [{"tags": ["x"]}]}`)
+	expected = map[string]interface{}{
+		"items.name":      []interface{}{"a", "b"},
+		"items.qty":       []interface{}{float64(1), float64(2)},
+		"nested.0.tags.0": "x",
+	}
+	result, _, err = goflat.FlattenJSONWithPositions(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Positions result %v does not match expected result", result)
+	}
+	options.TrackOrder = true
+	result, _, err = goflat.FlattenJSONOrdered(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Ordered result %v does not match expected result", result)
+	}
+}
+
+func TestIndexBucketSize(t *testing.T) {
+	// Test case 1: Indices are split into a bucket and an in-bucket index
+	items := make([]interface{}, 250)
+	for i := range items {
+		items[i] = float64(i)
+	}
+	data, err := json.Marshal(map[string]interface{}{"items": items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := goflat.DefaultOptions()
+	options.IndexBucketSize = 100
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if len(result) != 250 || result["items.bucket_0.7"] != float64(7) || result["items.bucket_2.49"] != float64(249) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Round trip across multiple buckets, including nested arrays
+	assertRoundTrip(t, data, options)
+	assertRoundTrip(t, []byte(`{"m": [[1, 2], [3]], "o": [{"a": [true]}]}`), options)
+
+	// Test case 3: Round trip with bracket notation
+	options.ArrayNotation = goflat.NotationBracket
+	assertRoundTrip(t, data, options)
+}
+
+// temperature is a custom fmt.Stringer used by TestUseStringer.
+type temperature float64
+
+func (t temperature) String() string {
+	return fmt.Sprintf("%.1f°C", float64(t))
+}
+
+func TestUseStringer(t *testing.T) {
+	// Test case 1: Stringer leaves are stored as strings
+	data := map[string]interface{}{
+		"timeout": 90 * time.Second,
+		"sensor": map[string]interface{}{
+			"temp": temperature(21.5),
+		},
+		"count": 3,
+	}
+	options := goflat.DefaultOptions()
+	options.UseStringer = true
+	expected := map[string]interface{}{
+		"timeout":     "1m30s",
+		"sensor.temp": "21.5°C",
+		"count":       3,
+	}
+	result := goflat.FlattenMap(data, options)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 2: Without the option the values are stored as they are
+	options.UseStringer = false
+	result = goflat.FlattenMap(data, options)
+	if result["timeout"] != 90*time.Second || result["sensor.temp"] != temperature(21.5) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func TestExpandLeaf(t *testing.T) {
+	// Test case 1: A "lat,lon" string is split into two keys
+	data := []byte(`{"place": {"name": "Office", "coords": "12.5,34.25"}}`)
+	options := goflat.DefaultOptions()
+	options.ExpandLeaf = func(key string, value interface{}) map[string]interface{} {
+		s, ok := value.(string)
+		if !strings.HasSuffix(key, ".coords") || !ok {
+			return nil
+		}
+		parts := strings.Split(s, ",")
+		return map[string]interface{}{
+			"lat": parts[0],
+			"lon": parts[1],
+		}
+	}
+	expected := map[string]interface{}{
+		"place.name":       "Office",
+		"place.coords.lat": "12.5",
+		"place.coords.lon": "34.25",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestPadIndexWidth(t *testing.T) {
+	// Test case 1: Indices are zero-padded so lexical order matches numeric order
+	items := make([]interface{}, 11)
+	for i := range items {
+		items[i] = float64(i)
+	}
+	data, err := json.Marshal(map[string]interface{}{"items": items})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := goflat.DefaultOptions()
+	options.PadIndexWidth = 4
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if result["items.0002"] != float64(2) || result["items.0010"] != float64(10) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+	if !("items.0002" < "items.0010") {
+		t.Errorf("Padded keys do not sort numerically")
+	}
+
+	// Test case 2: Round trip with dot and bracket notation
+	assertRoundTrip(t, data, options)
+	options.ArrayNotation = goflat.NotationBracket
+	assertRoundTrip(t, data, options)
+
+	// Test case 3: Indices wider than the width are widened, or rejected when strict
+	options = goflat.DefaultOptions()
+	options.PadIndexWidth = 1
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if result["items.10"] != float64(10) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+	options.PadIndexStrict = true
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrIndexTooWide) {
+		t.Errorf("Expected ErrIndexTooWide, got %v", err)
+	}
+}
+
+func TestFlattenMapStrict(t *testing.T) {
+	// Test case 1: A map without unsupported values flattens as usual
+	data := map[string]interface{}{
+		"name": "John",
+		"address": map[string]interface{}{
+			"city": addressCity,
+		},
+	}
+	options := goflat.DefaultOptions()
+	result, err := goflat.FlattenMapStrict(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"name": "John", addressCityKey: addressCity}) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 2: A channel value is rejected with its path
+	data["address"].(map[string]interface{})["updates"] = make(chan int)
+	_, err = goflat.FlattenMapStrict(data, options)
+	if !errors.Is(err, goflat.ErrUnsupportedKind) || !strings.Contains(err.Error(), `"address.updates"`) {
+		t.Errorf("Expected ErrUnsupportedKind for address.updates, got %v", err)
+	}
+
+	// Test case 3: A func value is rejected with its path
+	data = map[string]interface{}{
+		"callbacks": []interface{}{func() {}},
+	}
+	_, err = goflat.FlattenMapStrict(data, options)
+	if !errors.Is(err, goflat.ErrUnsupportedKind) || !strings.Contains(err.Error(), `"callbacks.0"`) {
+		t.Errorf("Expected ErrUnsupportedKind for callbacks.0, got %v", err)
+	}
+}
+
+func TestMinArrayKeys(t *testing.T) {
+	flattened := map[string]interface{}{
+		"one.0": "a",
+		"two.0": "a",
+		"two.1": "b",
+		"gap.0": "a",
+		"gap.2": "c",
+	}
+	options := goflat.DefaultOptions()
+
+	// Test case 1: Below the threshold nodes stay maps
+	options.MinArrayKeys = 2
+	expected := map[string]interface{}{
+		"one": map[string]interface{}{"0": "a"},
+		"two": []interface{}{"a", "b"},
+		"gap": map[string]interface{}{"0": "a", "2": "c"},
+	}
+	result, err := goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 2: Raising the threshold past the array length keeps it a map
+	options.MinArrayKeys = 3
+	result, err = goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result.(map[string]interface{})["two"], map[string]interface{}{"0": "a", "1": "b"}) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 3: At the default threshold single-element arrays are rebuilt
+	options.MinArrayKeys = 0
+	result, err = goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result.(map[string]interface{})["one"], []interface{}{"a"}) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+}
+
+func TestFlattenWith(t *testing.T) {
+	// Test case 1: A custom decoder for "key=value" lines with dotted keys nested
+	decode := func(data []byte) (map[string]interface{}, error) {
+		result := make(map[string]interface{})
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid line %q", line)
+			}
+			result[key] = map[string]interface{}{"value": value}
+		}
+		return result, nil
+	}
+	options := goflat.DefaultOptions()
+	expected := map[string]interface{}{
+		"name.value": "John",
+		"city.value": addressCity,
+	}
+	result, err := goflat.FlattenWith([]byte("name=John\ncity=New York\n"), decode, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Decoder errors are returned
+	_, err = goflat.FlattenWith([]byte("broken"), decode, options)
+	if err == nil {
+		t.Errorf("Expected decoder error")
+	}
+
+	// Test case 3: A nil decoder falls back to JSON
+	result, err = goflat.FlattenWith([]byte(`{"address": {"city": "New York"}}`), nil, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{addressCityKey: addressCity}) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestPrefixFromPath(t *testing.T) {
+	// Test case 1: The id value prefixes all other keys
+	data := []byte(`{"id": "alice", "name": "Alice", "address": {"city": "New York"}}`)
+	options := goflat.DefaultOptions()
+	options.PrefixFromPath = "id"
+	expected := map[string]interface{}{
+		"id":                 "alice",
+		"alice.name":         "Alice",
+		"alice.address.city": addressCity,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: A missing id leaves keys unprefixed
+	data = []byte(`{"name": "Alice"}`)
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"name": "Alice"}) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 3: A missing id fails when required
+	options.PrefixFromPathRequired = true
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrPrefixPathMissing) {
+		t.Errorf("Expected ErrPrefixPathMissing, got %v", err)
+	}
+}
+
+func TestArraysRequireBrackets(t *testing.T) {
+	// Test case 1: "a.0" stays a map key while "a[0]" is an array index
+	flattened := map[string]interface{}{
+		"codes.0":         "x",
+		"codes.1":         "y",
+		"tags[0]":         "red",
+		"tags[1]":         "blue",
+		"rows[0].cells.0": 1,
+		"rows[0].cells.1": 2,
+	}
+	options := goflat.DefaultOptions()
+	options.ArrayNotation = goflat.NotationBracket
+	options.ArraysRequireBrackets = true
+	expected := map[string]interface{}{
+		"codes": map[string]interface{}{"0": "x", "1": "y"},
+		"tags":  []interface{}{"red", "blue"},
+		"rows": []interface{}{
+			map[string]interface{}{
+				"cells": map[string]interface{}{"0": 1, "1": 2},
+			},
+		},
+	}
+	result, err := goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 2: Without the option numeric map keys are rebuilt as arrays
+	options.ArraysRequireBrackets = false
+	result, err = goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result.(map[string]interface{})["codes"], []interface{}{"x", "y"}) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 3: Numeric-keyed maps and arrays round-trip losslessly
+	options.ArraysRequireBrackets = true
+	assertRoundTrip(t, []byte(`{"codes": {"0": "x", "1": "y"}, "tags": ["red", ["blue"]]}`), options)
+}
+
+func TestTrimPrefix(t *testing.T) {
+	// Test case 1: A two-segment prefix is stripped and other keys are kept
+	data := []byte(`{"data": {"attributes": {"name": "John", "address": {"city": "New York"}}, "id": 7}, "links": {"self": "/u/7"}}`)
+	options := goflat.DefaultOptions()
+	options.TrimPrefix = "data.attributes"
+	expected := map[string]interface{}{
+		"name":         "John",
+		addressCityKey: addressCity,
+		"data.id":      float64(7),
+		"links.self":   "/u/7",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Keys lacking the prefix are dropped when requested
+	options.TrimPrefixDropOthers = true
+	expected = map[string]interface{}{
+		"name":         "John",
+		addressCityKey: addressCity,
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestFlattenMapSafe(t *testing.T) {
+	// Test case 1: Mutations of the source during the walk are not observed
+	data := map[string]interface{}{
+		"address": map[string]interface{}{
+			"city":   addressCity,
+			"street": addressStreet,
+		},
+		"hobbies": []interface{}{hobbies0, hobbies1},
+	}
+	options := goflat.DefaultOptions()
+	options.ExpandLeaf = func(key string, value interface{}) map[string]interface{} {
+		data["address"].(map[string]interface{})["zip"] = "10001"
+		data["hobbies"].([]interface{})[1] = "hiking"
+		return nil
+	}
+	expected := map[string]interface{}{
+		addressCityKey:   addressCity,
+		addressStreetKey: addressStreet,
+		hobbies0Key:      hobbies0,
+		hobbies1Key:      hobbies1,
+	}
+	result := goflat.FlattenMapSafe(data, options)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 2: Mutations after the call do not affect the result
+	data["address"].(map[string]interface{})["city"] = "Boston"
+	if result[addressCityKey] != addressCity {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func TestMaxBreadth(t *testing.T) {
+	// Test case 1: A map exactly at the limit
+	data := []byte(`{"a": {"x": 1, "y": 2, "z": 3}, "b": 4}`)
+	options := goflat.DefaultOptions()
+	options.MaxBreadth = 3
+	_, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+
+	// Test case 2: A nested map over the limit names its path
+	data = []byte(`{"a": {"b": {"w": 0, "x": 1, "y": 2, "z": 3}}}`)
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrBreadthExceeded) || !strings.Contains(err.Error(), `"a.b"`) {
+		t.Errorf("Expected ErrBreadthExceeded for a.b, got %v", err)
+	}
+
+	// Test case 3: The top-level object is checked too
+	data = []byte(`{"w": 0, "x": 1, "y": 2, "z": 3}`)
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrBreadthExceeded) {
+		t.Errorf("Expected ErrBreadthExceeded, got %v", err)
+	}
+}
+
+// celsius is a custom type used by TestTypeEncoders.
+type celsius struct {
+	Degrees float64
+}
+
+func TestTypeEncoders(t *testing.T) {
+	// Test case 1: Encoders for []byte, time.Time and a custom type
+	when := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	data := map[string]interface{}{
+		"payload": []byte("hi"),
+		"meta": map[string]interface{}{
+			"created": when,
+			"temp":    celsius{Degrees: 21.5},
+		},
+		"tags": []string{"a"},
+	}
+	options := goflat.DefaultOptions()
+	options.TypeEncoders = map[reflect.Type]func(interface{}) interface{}{
+		reflect.TypeOf([]byte(nil)): func(v interface{}) interface{} {
+			return base64.StdEncoding.EncodeToString(v.([]byte))
+		},
+		reflect.TypeOf(time.Time{}): func(v interface{}) interface{} {
+			return v.(time.Time).Format(time.RFC3339)
+		},
+		reflect.TypeOf(celsius{}): func(v interface{}) interface{} {
+			return fmt.Sprintf("%gC", v.(celsius).Degrees)
+		},
+	}
+	expected := map[string]interface{}{
+		"payload":      "aGk=",
+		"meta.created": "2024-05-01T12:30:00Z",
+		"meta.temp":    "21.5C",
+		"tags.0":       "a",
+	}
+	result := goflat.FlattenMap(data, options)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func TestOpaquePaths(t *testing.T) {
+	// Test case 1: A matching subtree stays a nested leaf while siblings flatten
+	data := []byte(`{"config": {"raw": {"a": {"b": 1}, "list": [1, 2]}, "name": "svc", "env": {"mode": "prod"}}, "items": [{"meta": {"x": 1}}, {"meta": {"y": 2}}]}`)
+	options := goflat.DefaultOptions()
+	options.OpaquePaths = []string{"config.raw", "items.*.meta"}
+	expected := map[string]interface{}{
+		"config.raw": map[string]interface{}{
+			"a":    map[string]interface{}{"b": float64(1)},
+			"list": []interface{}{float64(1), float64(2)},
+		},
+		"config.name":     "svc",
+		"config.env.mode": "prod",
+		"items.0.meta":    map[string]interface{}{"x": float64(1)},
+		"items.1.meta":    map[string]interface{}{"y": float64(2)},
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestLeafVisitor(t *testing.T) {
+	// Test case 1: The visitor receives the ancestor segments of a nested leaf
+	data := []byte(`{"org": {"teams": [{"lead": {"name": "Ann"}}]}}`)
+	var paths [][]string
+	options := goflat.DefaultOptions()
+	options.LeafVisitor = func(path []string, value interface{}) {
+		paths = append(paths, path)
+		if value != "Ann" {
+			t.Errorf("Unexpected leaf value: %v", value)
+		}
+	}
+	if _, err := goflat.FlattenJSON(data, options); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected := [][]string{{"org", "teams", "0", "lead", "name"}}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Visited paths do not match expected result: %v", paths)
+	}
+
+	// Test case 2: Escaped delimiters and bracketed indices are split like PathSplit
+	data = []byte(`{"a.b": {"list": [true]}}`)
+	paths = nil
+	options = goflat.DefaultOptions()
+	options.EscapeChar = '\\'
+	options.ArrayNotation = goflat.NotationBracket
+	options.LeafVisitor = func(path []string, value interface{}) {
+		paths = append(paths, path)
+	}
+	if _, err := goflat.FlattenJSON(data, options); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected = [][]string{{"a.b", "list", "[0]"}}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Visited paths do not match expected result: %v", paths)
+	}
+}
+
+func TestContainerBoundaries(t *testing.T) {
+	// Test case 1: Enter and leave events bracket the leaves of a nested document in walk order
+	data := []byte(`{"a": {"b": [1, {"c": true}]}}`)
+	var events []string
+	record := func(event string) func(path []string) {
+		return func(path []string) { events = append(events, event+" "+strings.Join(path, "/")) }
+	}
+	options := goflat.DefaultOptions()
+	options.OnEnterObject = record("enter object")
+	options.OnLeaveObject = record("leave object")
+	options.OnEnterArray = func(path []string, length int) {
+		events = append(events, "enter array "+strings.Join(path, "/")+" "+fmt.Sprint(length))
+	}
+	options.OnLeaveArray = record("leave array")
+	leaf := record("leaf")
+	options.LeafVisitor = func(path []string, _ interface{}) { leaf(path) }
+	if _, err := goflat.FlattenJSON(data, options); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected := []string{
+		"enter object ",
+		"enter object a",
+		"enter array a/b 2",
+		"leaf a/b/0",
+		"enter object a/b/1",
+		"leaf a/b/1/c",
+		"leave object a/b/1",
+		"leave array a/b",
+		"leave object a",
+		"leave object ",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Boundary events do not match expected result: %v", events)
+	}
+
+	// Test case 2: Typed slices raise array events and empty containers still raise both events
+	events = nil
+	document := map[string]interface{}{"tags": []string{"x"}, "meta": map[string]interface{}{}}
+	options.LeafVisitor = nil
+	goflat.FlattenMap(document, options)
+	sort.Strings(events)
+	expected = []string{
+		"enter array tags 1",
+		"enter object ",
+		"enter object meta",
+		"leave array tags",
+		"leave object ",
+		"leave object meta",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Boundary events do not match expected result: %v", events)
+	}
+
+	// Test case 3: Arrays stored whole are leaves and raise no array events
+	events = nil
+	options.PreserveArrays = true
+	if _, err := goflat.FlattenJSON([]byte(`{"hobbies": ["reading", "gaming"]}`), options); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected = []string{"enter object ", "leave object "}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Boundary events do not match expected result: %v", events)
+	}
+}
+
+func TestElideBase64(t *testing.T) {
+	// Test case 1: Long base64 strings and data URIs are elided, short strings are kept
+	blob := base64.StdEncoding.EncodeToString(make([]byte, 300))
+	data := []byte(`{"file": "` + blob + `", "avatar": "data:image/png;base64,` + blob + `", "name": "John", "word": "abcd"}`)
+	options := goflat.DefaultOptions()
+	options.ElideBase64 = true
+	options.Base64Threshold = 64
+	expected := map[string]interface{}{
+		"file":   "<base64:300 bytes>",
+		"avatar": "<base64:300 bytes>",
+		"name":   "John",
+		"word":   "abcd",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Long strings that are not base64 are kept
+	text := strings.Repeat("not base64! ", 10)
+	data = []byte(`{"text": "` + text + `"}`)
+	expected = map[string]interface{}{"text": text}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestUnflattenPrefix(t *testing.T) {
+	// Test case 1: Only the address subtree is rebuilt from a large flat map
+	flattened := map[string]interface{}{
+		addressStreetKey: addressStreet,
+		addressCityKey:   addressCity,
+		"addressBook.0":  "Ann",
+		hobbies0Key:      hobbies0,
+		hobbies1Key:      hobbies1,
+		"name":           "John",
+	}
+	for i := 0; i < 100; i++ {
+		flattened[fmt.Sprintf("items.%d.id", i)] = i
+	}
+	options := goflat.DefaultOptions()
+	expected := map[string]interface{}{
+		"street": addressStreet,
+		"city":   addressCity,
+	}
+	result, err := goflat.UnflattenPrefix(flattened, "address", options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 2: A subtree that is an array comes back as one
+	result, err = goflat.UnflattenPrefix(flattened, "hobbies", options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, []interface{}{hobbies0, hobbies1}) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 3: A bracketed subtree and a scalar prefix
+	options.ArrayNotation = goflat.NotationBracket
+	flattened = map[string]interface{}{
+		"users[0].name": "Ann",
+		"users[1].name": "Bob",
+		"total":         2,
+	}
+	result, err = goflat.UnflattenPrefix(flattened, "users", options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	expectedUsers := []interface{}{
+		map[string]interface{}{"name": "Ann"},
+		map[string]interface{}{"name": "Bob"},
+	}
+	if !reflect.DeepEqual(result, expectedUsers) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+	result, err = goflat.UnflattenPrefix(flattened, "total", options)
+	if err != nil || result != 2 {
+		t.Errorf("Expected the scalar 2, got %v (%v)", result, err)
+	}
+}
+
+func TestMaxKeys(t *testing.T) {
+	// Test case 1: A document producing more keys than MaxKeys fails
+	data := []byte(`{"hobbies": ["reading", "gaming", "coding"]}`)
+	options := goflat.DefaultOptions()
+	options.MaxKeys = 2
+	_, err := goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrTooManyKeys) {
+		t.Errorf("Expected ErrTooManyKeys, got %v", err)
+	}
+
+	// Test case 2: A document at the limit is flattened
+	options.MaxKeys = 3
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if len(result) != 3 {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestArrayKeyField(t *testing.T) {
+	// Test case 1: Elements are keyed by their id, falling back to the index without one
+	data := []byte(`{"users": [{"id": "alice", "age": 30}, {"id": "bob", "age": 25}, {"age": 40}, "guest"]}`)
+	options := goflat.DefaultOptions()
+	options.ArrayKeyField = "id"
+	expected := map[string]interface{}{
+		"users.alice.id":  "alice",
+		"users.alice.age": float64(30),
+		"users.bob.id":    "bob",
+		"users.bob.age":   float64(25),
+		"users.2.age":     float64(40),
+		"users.3":         "guest",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Duplicate ids are rejected
+	data = []byte(`{"users": [{"id": "alice"}, {"id": "alice"}]}`)
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrKeyCollision) {
+		t.Errorf("Expected ErrKeyCollision, got %v", err)
+	}
+
+	// Test case 3: Elements are keyed by their id when positions or order are tracked
+	data = []byte(`{"users": [{"id": "alice", "age": 30}, {"id": "bob", "age": 25}, {"age": 40}, "guest"]}`)
+	result, positions, err := goflat.FlattenJSONWithPositions(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) || positions["users.bob.age"] != strings.Index(string(data), "25") {
+		t.Errorf("Positions result %v %v does not match expected result", result, positions)
+	}
+	options.TrackOrder = true
+	result, order, err := goflat.FlattenJSONOrdered(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) || len(order) != len(expected) || order[0] != "users.alice.id" {
+		t.Errorf("Ordered result %v %v does not match expected result", result, order)
+	}
+}
+
+func TestEmitArrayLength(t *testing.T) {
+	// Test case 1: Length keys are added for top-level and nested arrays
+	data := []byte(`{"hobbies": ["reading", "gaming"], "matrix": [[1, 2, 3], []], "tags": []}`)
+	options := goflat.DefaultOptions()
+	options.EmitArrayLength = true
+	expected := map[string]interface{}{
+		hobbies0Key:  hobbies0,
+		hobbies1Key:  hobbies1,
+		"hobbies.#":  2,
+		"matrix.#":   2,
+		"matrix.0.0": float64(1),
+		"matrix.0.1": float64(2),
+		"matrix.0.2": float64(3),
+		"matrix.0.#": 3,
+		"matrix.1.#": 0,
+		"tags.#":     0,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Unflattening consumes the length keys and restores empty arrays
+	assertRoundTrip(t, data, options)
+}
+
+func TestBoolFormat(t *testing.T) {
+	data := []byte(`{"active": true, "flags": {"admin": false}, "name": "John"}`)
+
+	// Test case 1: The default format keeps booleans
+	expected := map[string]interface{}{
+		"active":      true,
+		"flags.admin": false,
+		"name":        "John",
+	}
+	result, err := goflat.FlattenJSON(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: BoolOneZero stores 1 and 0
+	options := goflat.DefaultOptions()
+	options.BoolFormat = goflat.BoolOneZero
+	expected = map[string]interface{}{
+		"active":      1,
+		"flags.admin": 0,
+		"name":        "John",
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestNonFiniteFloatPolicy(t *testing.T) {
+	data := map[string]interface{}{
+		"nan":    math.NaN(),
+		"posInf": math.Inf(1),
+		"negInf": math.Inf(-1),
+		"ok":     1.5,
+	}
+
+	// Test case 1: NonFiniteError rejects each non-finite value
+	options := goflat.DefaultOptions()
+	options.NonFiniteFloatPolicy = goflat.NonFiniteError
+	for _, key := range []string{"nan", "posInf", "negInf"} {
+		_, err := goflat.FlattenMapStrict(map[string]interface{}{key: data[key]}, options)
+		if !errors.Is(err, goflat.ErrNonFiniteFloat) {
+			t.Errorf("Expected ErrNonFiniteFloat for %s, got %v", key, err)
+		}
+	}
+
+	// Test case 2: NonFiniteNull stores nil
+	options.NonFiniteFloatPolicy = goflat.NonFiniteNull
+	expected := map[string]interface{}{
+		"nan":    nil,
+		"posInf": nil,
+		"negInf": nil,
+		"ok":     1.5,
+	}
+	if result := goflat.FlattenMap(data, options); !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 3: NonFiniteString stores the names of the values
+	options.NonFiniteFloatPolicy = goflat.NonFiniteString
+	expected = map[string]interface{}{
+		"nan":    "NaN",
+		"posInf": "+Inf",
+		"negInf": "-Inf",
+		"ok":     1.5,
+	}
+	if result := goflat.FlattenMap(data, options); !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func TestPreserveArrays(t *testing.T) {
+	// Test case 1: Nested arrays stay slices while the maps around them flatten
+	data := []byte(`{"user": {"hobbies": ["reading", "gaming"], "address": {"city": "New York", "tags": [{"a": 1}]}}}`)
+	options := goflat.DefaultOptions()
+	options.PreserveArrays = true
+	expected := map[string]interface{}{
+		"user.hobbies":      []interface{}{hobbies0, hobbies1},
+		"user.address.city": addressCity,
+		"user.address.tags": []interface{}{map[string]interface{}{"a": float64(1)}},
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Typed slices are preserved too
+	m := map[string]interface{}{"ids": []int{1, 2}}
+	if result := goflat.FlattenMap(m, options); !reflect.DeepEqual(result, map[string]interface{}{"ids": []int{1, 2}}) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	bucket := func(key string, value interface{}) map[string]interface{} {
+		if price, ok := value.(float64); ok && strings.HasSuffix(key, "price") {
+			return map[string]interface{}{key + "_bucket": int(price) / 100 * 100}
+		}
+		return nil
+	}
+
+	// Test case 1: A bucketed key is derived from a numeric leaf
+	data := []byte(`{"item": {"name": "lamp", "price": 249.5}}`)
+	options := goflat.DefaultOptions()
+	options.Derive = bucket
+	expected := map[string]interface{}{
+		"item.name":         "lamp",
+		"item.price":        249.5,
+		"item.price_bucket": 200,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: A derived key colliding with a real key fails
+	data = []byte(`{"item": {"price": 249.5, "price_bucket": "manual"}}`)
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrKeyCollision) {
+		t.Errorf("Expected ErrKeyCollision, got %v", err)
+	}
+
+	// Test case 3: DeriveOverwrite lets the derived entry win
+	options.DeriveOverwrite = true
+	expected = map[string]interface{}{
+		"item.price":        249.5,
+		"item.price_bucket": 200,
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestLenientUnflatten(t *testing.T) {
+	flattened := map[string]interface{}{
+		"name":           "John",
+		addressCityKey:   addressCity,
+		"address..state": "NY",
+		"age.":           30,
+		".zip":           "10001",
+		hobbies0Key:      hobbies0,
+		"hobbies.0.x":    "conflict",
+	}
+
+	// Test case 1: Malformed and conflicting keys are skipped and reported
+	options := goflat.DefaultOptions()
+	options.LenientUnflatten = true
+	expected := map[string]interface{}{
+		"name":    "John",
+		"address": map[string]interface{}{"city": addressCity},
+		"hobbies": []interface{}{hobbies0},
+	}
+	result, skipped, err := goflat.UnflattenJSONWithSkipped(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+	expectedSkipped := []string{".zip", "address..state", "age.", "hobbies.0.x"}
+	if !reflect.DeepEqual(skipped, expectedSkipped) {
+		t.Errorf("Skipped keys do not match expected result: %v", skipped)
+	}
+
+	// Test case 2: Without the option the conflict fails the whole call
+	_, _, err = goflat.UnflattenJSONWithSkipped(flattened, goflat.DefaultOptions())
+	if !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+}
+
+func TestExpandJSONBytes(t *testing.T) {
+	data := map[string]interface{}{
+		"name":    "John",
+		"address": json.RawMessage(`{"city": "New York", "geo": [40.7, -74]}`),
+		"extra":   []byte(`{"a": {"b": true}}`),
+		"blob":    []byte("not json"),
+	}
+
+	// Test case 1: Raw JSON leaves are flattened inline, invalid bytes stay leaves
+	options := goflat.DefaultOptions()
+	options.ExpandJSONBytes = true
+	expected := map[string]interface{}{
+		"name":          "John",
+		addressCityKey:  addressCity,
+		"address.geo.0": 40.7,
+		"address.geo.1": float64(-74),
+		"extra.a.b":     true,
+		"blob":          []byte("not json"),
+	}
+	result, err := goflat.FlattenMapStrict(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 2: ExpandJSONBytesStrict rejects invalid bytes
+	options.ExpandJSONBytesStrict = true
+	_, err = goflat.FlattenMapStrict(data, options)
+	if !errors.Is(err, goflat.ErrInvalidJSONBytes) {
+		t.Errorf("Expected ErrInvalidJSONBytes, got %v", err)
+	}
+}
+
+func TestIndexLabel(t *testing.T) {
+	ends := func(i, n int) string {
+		switch i {
+		case 0:
+			return "first"
+		case n - 1:
+			return "last"
+		}
+		return ""
+	}
+
+	// Test case 1: The ends of an array are labeled and the middle keeps its indices
+	data := []byte(`{"samples": [1, 2, 3, 4], "tags": ["a", "b"]}`)
+	options := goflat.DefaultOptions()
+	options.IndexLabel = ends
+	expected := map[string]interface{}{
+		"samples.first": float64(1),
+		"samples.1":     float64(2),
+		"samples.2":     float64(3),
+		"samples.last":  float64(4),
+		"tags.first":    "a",
+		"tags.last":     "b",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Labels repeated within an array are rejected
+	options.IndexLabel = func(i, n int) string { return "same" }
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrKeyCollision) {
+		t.Errorf("Expected ErrKeyCollision, got %v", err)
+	}
+}
+
+func TestCompactBelowDepth(t *testing.T) {
+	// Test case 1: Subtrees at depth 2 become compact JSON strings
+	data := []byte(`{"name": "John", "user": {"address": {"city": "New York", "geo": [40.7, -74]}, "tags": ["a", "<b>"], "age": 30}}`)
+	options := goflat.DefaultOptions()
+	options.CompactBelowDepth = 2
+	expected := map[string]interface{}{
+		"name":         "John",
+		"user.address": `{"city":"New York","geo":[40.7,-74]}`,
+		"user.tags":    `["a","<b>"]`,
+		"user.age":     float64(30),
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Depth 1 compacts every top-level container, also with MaxDepth set
+	options.CompactBelowDepth = 1
+	options.MaxDepth = 5
+	expected = map[string]interface{}{
+		"name": "John",
+		"user": `{"address":{"city":"New York","geo":[40.7,-74]},"age":30,"tags":["a","<b>"]}`,
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestEmptyKeyReplacement(t *testing.T) {
+	// Test case 1: Empty keys at the top level and nested are replaced
+	data := []byte(`{"": 1, "a": {"": {"b": 2}, "c": [{"": true}]}}`)
+	options := goflat.DefaultOptions()
+	options.EmptyKeyReplacement = "_empty_"
+	expected := map[string]interface{}{
+		"_empty_":       float64(1),
+		"a._empty_.b":   float64(2),
+		"a.c.0._empty_": true,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Unflattening restores the empty keys
+	assertRoundTrip(t, data, options)
+}
+
+func TestUnwrapKeys(t *testing.T) {
+	// Test case 1: A data wrapper is peeled before flattening
+	data := []byte(`{"data": {"name": "John", "address": {"city": "New York"}}}`)
+	options := goflat.DefaultOptions()
+	options.UnwrapKeys = []string{"data"}
+	expected := map[string]interface{}{
+		"name":         "John",
+		addressCityKey: addressCity,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: A chain of wrappers is peeled
+	options.UnwrapKeys = []string{"response", "data"}
+	result, err = goflat.FlattenJSON([]byte(`{"response": {"data": {"name": "John"}}}`), options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"name": "John"}) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 3: A non-matching document is flattened as it is
+	data = []byte(`{"data": {"name": "John"}, "meta": {"page": 1}}`)
+	options.UnwrapKeys = []string{"data"}
+	expected = map[string]interface{}{
+		"data.name": "John",
+		"meta.page": float64(1),
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 4: A non-matching document fails with UnwrapKeysRequired
+	options.UnwrapKeysRequired = true
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrUnwrapMismatch) {
+		t.Errorf("Expected ErrUnwrapMismatch, got %v", err)
+	}
+}
+
+func TestSubtreeKeys(t *testing.T) {
+	flattened := map[string]interface{}{
+		addressStreetKey: addressStreet,
+		addressCityKey:   addressCity,
+		"addressBook.0":  "Ann",
+		hobbies0Key:      hobbies0,
+		"name":           "John",
+	}
+
+	// Test case 1: A prefix matching a subtree
+	expected := map[string]interface{}{
+		addressStreetKey: addressStreet,
+		addressCityKey:   addressCity,
+	}
+	if result := goflat.SubtreeKeys(flattened, "address", goflat.DefaultOptions()); !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 2: A prefix matching a single leaf
+	expected = map[string]interface{}{"name": "John"}
+	if result := goflat.SubtreeKeys(flattened, "name", goflat.DefaultOptions()); !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 3: A prefix matching nothing
+	if result := goflat.SubtreeKeys(flattened, "missing", goflat.DefaultOptions()); len(result) != 0 {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func TestFlattenPointerContainers(t *testing.T) {
+	hobbies := []interface{}{hobbies0, hobbies1}
+	address := map[string]interface{}{"city": addressCity}
+	var missing *[]interface{}
+	data := map[string]interface{}{
+		"name":    "John",
+		"hobbies": &hobbies,
+		"address": &address,
+		"tags":    &[]string{"a"},
+		"missing": missing,
+	}
+
+	// Test case 1: Pointers to slices and maps flatten to indexed and dotted keys
+	expected := map[string]interface{}{
+		"name":         "John",
+		hobbies0Key:    hobbies0,
+		hobbies1Key:    hobbies1,
+		addressCityKey: addressCity,
+		"tags.0":       "a",
+		"missing":      nil,
+	}
+	result := goflat.FlattenMap(data, goflat.DefaultOptions())
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 2: The flattened keys unflatten into plain containers
+	unflattened, err := goflat.UnflattenJSON(result, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	nested, _ := unflattened.(map[string]interface{})
+	if !reflect.DeepEqual(nested["hobbies"], hobbies) || !reflect.DeepEqual(nested["address"], address) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+}
+
+func TestMixedNotation(t *testing.T) {
+	// Test case 1: Bracketed indices and dots in the same keys rebuild arrays and maps
+	flattened := map[string]interface{}{
+		"a.b[0].c[1].d":   "x",
+		"a.b[0].c[0]":     float64(1),
+		"a.b[1]":          true,
+		"users[0].name":   "Ann",
+		"users[1].tags.0": "admin",
+		"m[0][0]":         "first",
+		"m[0][1]":         "second",
+	}
+	options := goflat.DefaultOptions()
+	options.MixedNotation = true
+	expected := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": []interface{}{
+				map[string]interface{}{"c": []interface{}{float64(1), map[string]interface{}{"d": "x"}}},
+				true,
+			},
+		},
+		"users": []interface{}{
+			map[string]interface{}{"name": "Ann"},
+			map[string]interface{}{"tags": []interface{}{"admin"}},
+		},
+		"m": []interface{}{[]interface{}{"first", "second"}},
+	}
+	result, err := goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 2: Without MixedNotation brackets are part of the map keys
+	result, err = goflat.UnflattenJSON(map[string]interface{}{"a.b[0]": 1}, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"a": map[string]interface{}{"b[0]": 1}}) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 3: PathSplit tokenizes mixed keys
+	if split := goflat.PathSplit("a.b[0].c[1].d", options); !reflect.DeepEqual(split, []string{"a", "b", "[0]", "c", "[1]", "d"}) {
+		t.Errorf("Split segments do not match: %v", split)
+	}
+}
+
+func TestTrimSpace(t *testing.T) {
+	// Test case 1: Padded keys and string values are trimmed, other values are untouched
+	data := []byte(`{" name ": "  John ", "address": {"city\t": " New York"}, "tags": [" a ", 1]}`)
+	options := goflat.DefaultOptions()
+	options.TrimSpace = true
+	expected := map[string]interface{}{
+		"name":         "John",
+		addressCityKey: addressCity,
+		"tags.0":       "a",
+		"tags.1":       float64(1),
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Keys differing only in surrounding spaces collide
+	data = []byte(`{"user": {"id": 1, " id": 2}}`)
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrKeyCollision) {
+		t.Errorf("Expected ErrKeyCollision, got %v", err)
+	}
+
+	// Test case 3: With TrimSpaceLastWins the key sorting last is kept
+	options.TrimSpaceLastWins = true
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"user.id": float64(1)}) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestArrayPolicy(t *testing.T) {
+	data := []byte(`{
+		"tags": ["a", "b"],
+		"users": [{"id": "alice", "age": 30}, {"id": "bob", "age": 25}],
+		"matrix": [[1, 2], {"x": 1}]
+	}`)
+
+	// Test case 1: Object arrays are keyed by field while scalar arrays are kept whole
+	options := goflat.DefaultOptions()
+	options.ArrayKeyField = "id"
+	options.ArrayPolicy = goflat.ArrayPolicy{
+		ObjectArrays: goflat.ArrayKeyByField,
+		ScalarArrays: goflat.ArrayPreserveWhole,
+		MixedArrays:  goflat.ArrayIndex,
+	}
+	expected := map[string]interface{}{
+		"tags":            []interface{}{"a", "b"},
+		"users.alice.id":  "alice",
+		"users.alice.age": float64(30),
+		"users.bob.id":    "bob",
+		"users.bob.age":   float64(25),
+		"matrix.0":        []interface{}{float64(1), float64(2)},
+		"matrix.1.x":      float64(1),
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Scalar arrays are joined and object arrays indexed, despite ArrayKeyField
+	options.ArrayPolicy = goflat.ArrayPolicy{
+		ObjectArrays:  goflat.ArrayIndex,
+		ScalarArrays:  goflat.ArrayJoinScalars,
+		JoinSeparator: "|",
+	}
+	expected = map[string]interface{}{
+		"tags":        "a|b",
+		"users.0.id":  "alice",
+		"users.0.age": float64(30),
+		"users.1.id":  "bob",
+		"users.1.age": float64(25),
+		"matrix.0":    "1|2",
+		"matrix.1.x":  float64(1),
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 3: Inapplicable modes fall back to the other options
+	options = goflat.DefaultOptions()
+	options.ArrayPolicy = goflat.ArrayPolicy{ScalarArrays: goflat.ArrayKeyByField, ObjectArrays: goflat.ArrayJoinScalars}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected, err = goflat.FlattenJSON(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestInternStrings(t *testing.T) {
+	data := []byte(`{"a": {"status": "active"}, "b": {"status": "active"}, "c": ["active", "idle"]}`)
+
+	// Test case 1: The output is the same with and without interning
+	options := goflat.DefaultOptions()
+	options.InternStrings = true
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected, err := goflat.FlattenJSON(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Equal strings share one backing array
+	first := unsafe.StringData(result["a.status"].(string))
+	for _, key := range []string{"b.status", "c.0"} {
+		if unsafe.StringData(result[key].(string)) != first {
+			t.Errorf("Expected %s to share the interned string", key)
+		}
+	}
+
+	// Test case 3: Strings past the capped table are kept as they are
+	values := make([]interface{}, 5000)
+	for i := range values {
+		values[i] = strconv.Itoa(i % 4500)
+	}
+	result = goflat.FlattenMap(map[string]interface{}{"v": values}, options)
+	if len(result) != 5000 || result["v.4999"] != "499" || result["v.4600"] != "100" {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func BenchmarkInternStrings(b *testing.B) {
+	// rows builds a document with fresh copies of its repeated values, as decoders that do not
+	// share equal strings produce.
+	rows := func() map[string]interface{} {
+		rows := make([]interface{}, 1000)
+		for i := range rows {
+			rows[i] = map[string]interface{}{
+				"status": strings.Clone("active"),
+				"agent":  strings.Clone("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"),
+			}
+		}
+		return map[string]interface{}{"rows": rows}
+	}
+
+	for _, intern := range []bool{false, true} {
+		options := goflat.DefaultOptions()
+		options.InternStrings = intern
+		name := "plain"
+		if intern {
+			name = "interned"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			var retained int64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				result := goflat.FlattenMap(rows(), options)
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				retained += int64(after.HeapAlloc) - int64(before.HeapAlloc)
+				runtime.KeepAlive(result)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "heap-B/op")
+		})
+	}
+}
+
+func TestHoistCommonFields(t *testing.T) {
+	data := []byte(`{"orders": [
+		{"id": 1, "currency": "USD", "total": 10, "meta": {"v": 1}},
+		{"id": 2, "currency": "USD", "total": 10, "meta": {"v": 1}},
+		{"id": 3, "currency": "USD", "total": 12, "meta": {"v": 1}}
+	], "single": [{"a": 1}]}`)
+
+	// Test case 1: The shared field is hoisted, non-uniform and nested fields stay per element
+	options := goflat.DefaultOptions()
+	options.HoistCommonFields = true
+	expected := map[string]interface{}{
+		"orders.currency": "USD",
+		"orders.0.id":     float64(1),
+		"orders.0.total":  float64(10),
+		"orders.0.meta.v": float64(1),
+		"orders.1.id":     float64(2),
+		"orders.1.total":  float64(10),
+		"orders.1.meta.v": float64(1),
+		"orders.2.id":     float64(3),
+		"orders.2.total":  float64(12),
+		"orders.2.meta.v": float64(1),
+		"single.0.a":      float64(1),
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Nothing is hoisted by default
+	result, err = goflat.FlattenJSON(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if _, ok := result["orders.currency"]; ok || result["orders.1.currency"] != "USD" {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
+
+func TestEmitNumericAggregates(t *testing.T) {
+	data := []byte(`{"scores": [3, 9, 6], "mixed": [1, "two"], "empty": []}`)
+	options := goflat.DefaultOptions()
+	options.EmitNumericAggregates = true
+
+	// Test case 1: Numeric arrays get aggregate keys alongside their indices
+	expected := map[string]interface{}{
+		"scores.0":   float64(3),
+		"scores.1":   float64(9),
+		"scores.2":   float64(6),
+		"scores.min": float64(3),
+		"scores.max": float64(9),
+		"scores.sum": float64(18),
+		"scores.avg": float64(6),
+		"mixed.0":    float64(1),
+		"mixed.1":    "two",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Typed numeric slices are aggregated too
+	result = goflat.FlattenMap(map[string]interface{}{"n": []int{1, 2}}, options)
+	if result["n.sum"] != float64(3) || result["n.avg"] != 1.5 {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func TestBytesEncoding(t *testing.T) {
+	data := map[string]interface{}{"blob": []byte("hi!"), "nested": map[string]interface{}{"key": []byte{0xde, 0xad}}}
+
+	// Test case 1: Byte slices are stored as they are by default
+	result := goflat.FlattenMap(data, goflat.DefaultOptions())
+	if !reflect.DeepEqual(result["blob"], []byte("hi!")) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 2: Base64 encoding
+	options := goflat.DefaultOptions()
+	options.BytesEncoding = goflat.BytesBase64
+	expected := map[string]interface{}{"blob": "aGkh", "nested.key": "3q0="}
+	if result = goflat.FlattenMap(data, options); !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 3: Hex encoding
+	options.BytesEncoding = goflat.BytesHex
+	expected = map[string]interface{}{"blob": "686921", "nested.key": "dead"}
+	if result = goflat.FlattenMap(data, options); !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func TestFlattenRaw(t *testing.T) {
+	// Test case 1: A top-level RawMessage flattens like its bytes
+	raw := json.RawMessage(`{"name": "John", "address": {"city": "New York"}}`)
+	expected := map[string]interface{}{"name": "John", addressCityKey: addressCity}
+	result, err := goflat.FlattenRaw(raw, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Nested RawMessage leaves are expanded, other byte slices are not
+	data := map[string]interface{}{
+		"payload": json.RawMessage(`{"id": 7, "tags": ["a"]}`),
+		"blob":    []byte(`{"id": 8}`),
+	}
+	options := goflat.DefaultOptions()
+	options.ExpandRawMessage = true
+	expected = map[string]interface{}{
+		"payload.id":     float64(7),
+		"payload.tags.0": "a",
+		"blob":           []byte(`{"id": 8}`),
+	}
+	if result = goflat.FlattenMap(data, options); !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 3: Without the option RawMessage leaves are kept whole
+	if result = goflat.FlattenMap(data, goflat.DefaultOptions()); !reflect.DeepEqual(result["payload"], data["payload"]) || len(result) != 2 {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 4: Named byte slices are leaves and follow BytesEncoding
+	type digest []byte
+	data = map[string]interface{}{"sum": digest{0xca, 0xfe}, "raw": json.RawMessage(`[1]`)}
+	if result = goflat.FlattenMap(data, goflat.DefaultOptions()); !reflect.DeepEqual(result, data) || !goflat.IsFlat(result) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+	options = goflat.DefaultOptions()
+	options.BytesEncoding = goflat.BytesHex
+	expected = map[string]interface{}{"sum": "cafe", "raw": "5b315d"}
+	if result = goflat.FlattenMap(data, options); !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+}
+
+func TestMaxStringLength(t *testing.T) {
+	data := []byte(`{"bio": "a very long biography", "name": "John", "city": "Zürich café"}`)
+
+	// Test case 1: Long strings are cut with a marker, short ones are untouched
+	options := goflat.DefaultOptions()
+	options.MaxStringLength = 6
+	expected := map[string]interface{}{
+		"bio":  "a very…",
+		"name": "John",
+		"city": "Zürich…",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: The sidecar records the original length in characters
+	options.StringLengthSidecar = true
+	expected["bio#len"] = 21
+	expected["city#len"] = 11
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
@@ -0,0 +1,121 @@
+package goflat
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultPrefixCacheSize is the number of keys a Flattener caches when Options.PrefixCacheSize
+// is not positive.
+const DefaultPrefixCacheSize = 4096
+
+// Flattener flattens many documents with the same options, caching the keys it builds so that
+// documents sharing their structure reuse them instead of joining the same strings again. The
+// cache is bounded and evicts the least recently used keys. A Flattener is safe for concurrent
+// use by multiple goroutines.
+type Flattener struct {
+	options  Options
+	prefixes *prefixCache
+}
+
+// NewFlattener creates a Flattener that flattens with options, caching up to
+// options.PrefixCacheSize keys.
+//
+// Example:
+//
+//	fl := NewFlattener(DefaultOptions())
+//	for _, data := range [][]byte{
+//		[]byte(`{"address": {"city": "New York"}}`),
+//		[]byte(`{"address": {"city": "Boston"}}`),
+//	} {
+//		flattened, err := fl.Flatten(data)
+//		if err != nil {
+//			fmt.Println("Error:", err)
+//			return
+//		}
+//		fmt.Println(flattened)
+//	}
+//
+// Output:
+//
+//	map[address.city:New York]
+//	map[address.city:Boston]
+func NewFlattener(options Options) *Flattener {
+	size := options.PrefixCacheSize
+	if size <= 0 {
+		size = DefaultPrefixCacheSize
+	}
+	return &Flattener{options: options, prefixes: newPrefixCache(size)}
+}
+
+// Flatten flattens a JSON object like FlattenJSON with the options of the Flattener.
+func (fl *Flattener) Flatten(data []byte) (map[string]interface{}, error) {
+	decoded, err := jsonDecoder(fl.options)(data)
+	if err != nil {
+		return nil, err
+	}
+	f := newFlattener(fl.options)
+	f.prefixes = fl.prefixes
+	f.run(decoded)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.flattened, nil
+}
+
+// prefixKey identifies a key built by a flattener from its parent key and one more segment.
+type prefixKey struct {
+	parent string
+	member string // The map key, for members
+	index  int    // The array index, for elements; -1 for members
+	root   bool   // Whether the member is top-level, built without a parent
+}
+
+// prefixEntry is a cached key, held in the recency list of a prefixCache.
+type prefixEntry struct {
+	id  prefixKey
+	key string
+}
+
+// prefixCache is a concurrency-safe LRU cache of built keys shared by the flatteners of a
+// Flattener.
+type prefixCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[prefixKey]*list.Element
+	recency *list.List // Most recently used first
+}
+
+// newPrefixCache is a helper function that creates a prefixCache holding up to size keys.
+func newPrefixCache(size int) *prefixCache {
+	return &prefixCache{size: size, entries: make(map[prefixKey]*list.Element), recency: list.New()}
+}
+
+// get is a helper function that returns the cached key for id and marks it as recently used.
+func (c *prefixCache) get(id prefixKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[id]
+	if !ok {
+		return "", false
+	}
+	c.recency.MoveToFront(elem)
+	return elem.Value.(*prefixEntry).key, true
+}
+
+// put is a helper function that caches key for id, evicting the least recently used key when the
+// cache is full.
+func (c *prefixCache) put(id prefixKey, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		c.recency.MoveToFront(elem)
+		return
+	}
+	if c.recency.Len() >= c.size {
+		oldest := c.recency.Back()
+		c.recency.Remove(oldest)
+		delete(c.entries, oldest.Value.(*prefixEntry).id)
+	}
+	c.entries[id] = c.recency.PushFront(&prefixEntry{id: id, key: key})
+}
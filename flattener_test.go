@@ -0,0 +1,109 @@
+package goflat_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+// similarDocument returns the i-th of a series of documents sharing their structure.
+func similarDocument(i int) []byte {
+	return []byte(fmt.Sprintf(`{"id": %d, "user": {"name": "user%d", "address": {"street": "%d Main St", "city": "New York"}}, "orders": [{"sku": "a%d", "qty": %d}, {"sku": "b%d", "qty": 1}], "tags": ["x", "y", "z"]}`, i, i, i, i, i, i))
+}
+
+func TestFlattener(t *testing.T) {
+	// Test case 1: Results match FlattenJSON with several key styles, including on cache hits
+	bracket := goflat.DefaultOptions()
+	bracket.ArrayNotation = goflat.NotationBracket
+	upper := goflat.DefaultOptions()
+	upper.KeyCase = goflat.CaseUpper
+	for _, options := range []goflat.Options{goflat.DefaultOptions(), bracket, upper, goflat.JSONPointerOptions()} {
+		fl := goflat.NewFlattener(options)
+		for i := 0; i < 3; i++ {
+			data := similarDocument(i)
+			expected, err := goflat.FlattenJSON(data, options)
+			if err != nil {
+				t.Fatalf(errorFlatteningJSON, err)
+			}
+			result, err := fl.Flatten(data)
+			if err != nil {
+				t.Errorf(errorFlatteningJSON, err)
+			}
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("Flattener result does not match FlattenJSON: %v", result)
+			}
+		}
+	}
+
+	// Test case 2: A cache smaller than one document evicts keys and stays correct
+	options := goflat.DefaultOptions()
+	options.PrefixCacheSize = 2
+	fl := goflat.NewFlattener(options)
+	for i := 0; i < 3; i++ {
+		expected, _ := goflat.FlattenJSON(similarDocument(i), options)
+		if result, err := fl.Flatten(similarDocument(i)); err != nil || !reflect.DeepEqual(result, expected) {
+			t.Errorf("Flattener result does not match FlattenJSON: %v, %v", result, err)
+		}
+	}
+
+	// Test case 3: Keys that fail keep failing once the cache is warm
+	fl = goflat.NewFlattener(goflat.MongoOptions())
+	for i := 0; i < 2; i++ {
+		if _, err := fl.Flatten([]byte(`{"a": {"b.c": 1}}`)); !errors.Is(err, goflat.ErrInvalidFieldName) {
+			t.Errorf("Expected ErrInvalidFieldName, got %v", err)
+		}
+	}
+
+	// Test case 4: Concurrent calls share the cache safely
+	fl = goflat.NewFlattener(goflat.DefaultOptions())
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				data := similarDocument(g*50 + i)
+				expected, _ := goflat.FlattenJSON(data, goflat.DefaultOptions())
+				if result, err := fl.Flatten(data); err != nil || !reflect.DeepEqual(result, expected) {
+					t.Errorf("Flattener result does not match FlattenJSON: %v, %v", result, err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Test case 5: Invalid JSON is reported
+	if _, err := fl.Flatten([]byte(`{`)); err == nil {
+		t.Errorf("Expected an error for invalid JSON")
+	}
+}
+
+func BenchmarkFlattener(b *testing.B) {
+	documents := make([][]byte, 100)
+	for i := range documents {
+		documents[i] = similarDocument(i)
+	}
+	options := goflat.DefaultOptions()
+
+	b.Run("standard", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := goflat.FlattenJSON(documents[i%len(documents)], options); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		fl := goflat.NewFlattener(options)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := fl.Flatten(documents[i%len(documents)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
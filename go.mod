@@ -1,3 +1,5 @@
 module github.com/brian-s-side-project/go-flat
 
 go 1.22.3
+
+require golang.org/x/text v0.22.0
@@ -0,0 +1,65 @@
+package goflat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// gzipMagic is the header every gzip stream starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// FlattenGzipJSON flattens a gzip-compressed JSON object like FlattenJSON. Input that does not
+// start with the gzip magic bytes is flattened as plain JSON, so both forms are accepted.
+//
+// Example:
+//
+//	var buf bytes.Buffer
+//	zw := gzip.NewWriter(&buf)
+//	zw.Write([]byte(`{"address": {"city": "New York"}}`))
+//	zw.Close()
+//	flattened, err := FlattenGzipJSON(buf.Bytes(), DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(flattened)
+//
+// Output:
+//
+//	map[address.city:New York]
+func FlattenGzipJSON(data []byte, options Options) (map[string]interface{}, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return FlattenJSON(data, options)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	if err := zr.Close(); err != nil {
+		return nil, err
+	}
+	return FlattenJSON(plain, options)
+}
+
+// FlattenJSONToGzipBytes flattens a JSON object like FlattenJSONToBytes and returns the encoded
+// result gzip-compressed.
+func FlattenJSONToGzipBytes(data []byte, options Options) ([]byte, error) {
+	out, err := FlattenJSONToBytes(data, options)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(out); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
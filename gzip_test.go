@@ -0,0 +1,69 @@
+package goflat_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFlattenGzipJSON(t *testing.T) {
+	data := []byte(`{"name": "John", "address": {"city": "New York"}, "hobbies": ["reading", "gaming"]}`)
+	expected := map[string]interface{}{
+		"name":         "John",
+		addressCityKey: addressCity,
+		hobbies0Key:    hobbies0,
+		hobbies1Key:    hobbies1,
+	}
+
+	// Test case 1: Gzipped input is decompressed before flattening
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := goflat.FlattenGzipJSON(buf.Bytes(), goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Plain input is accepted as well
+	result, err = goflat.FlattenGzipJSON(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 3: Gzipped output decompresses to the flattened JSON
+	out, err := goflat.FlattenJSONToGzipBytes(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(plain, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
@@ -0,0 +1,152 @@
+package goflat
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FlattenJSONWithIndexHashes flattens a JSON object like FlattenJSON and also returns the index
+// behind every hash segment written for indices wider than options.MaxIndexDigits, keyed by the
+// flattened key of the array element, so that UnflattenJSONWithIndexHashes can rebuild the arrays.
+//
+// Example:
+//
+//	data := []byte(`{"ids": [0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10]}`)
+//	options := DefaultOptions()
+//	options.MaxIndexDigits = 1
+//	flattened, hashes, err := FlattenJSONWithIndexHashes(data, options)
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(len(flattened), hashes)
+//
+// Output:
+//
+//	11 map[ids.g:10]
+func FlattenJSONWithIndexHashes(data []byte, options Options) (map[string]interface{}, map[string]int, error) {
+	decoded, err := jsonDecoder(options)(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := newFlattener(options)
+	f.hashes = make(map[string]int)
+	f.run(decoded)
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	return f.flattened, f.hashes, nil
+}
+
+// UnflattenJSONWithIndexHashes unflattens like UnflattenJSON after replacing the hash segments
+// of the element keys listed in hashes, as returned by FlattenJSONWithIndexHashes, with the
+// indices they stand for. Only segments in those array positions are replaced, so map keys that
+// look like a hash are kept.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"ids.0": "a", "ids.g": "b"}
+//	unflattened, err := UnflattenJSONWithIndexHashes(flattened, map[string]int{"ids.g": 1}, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(unflattened)
+//
+// Output:
+//
+//	map[ids:[a b]]
+func UnflattenJSONWithIndexHashes(flattened map[string]interface{}, hashes map[string]int, options Options) (interface{}, error) {
+	if len(hashes) == 0 {
+		return UnflattenJSON(flattened, options)
+	}
+	restored := make(map[string]interface{}, len(flattened))
+	for key, value := range flattened {
+		restored[unhashIndices(key, hashes, options)] = value
+	}
+	return UnflattenJSON(restored, options)
+}
+
+// hashIndex is a helper function that returns the segment written for index i under key per
+// Options.MaxIndexDigits and records the element key it makes. The segment is a hash of i in
+// MaxIndexDigits base-36 characters, at least one of them a letter so that it never reads as an
+// index; when it is already taken under key the next free one is used. It fails with
+// ErrIndexTooWide when every segment of that width is taken.
+func (f *flattener) hashIndex(key string, i int) string {
+	width := f.options.MaxIndexDigits
+	space := uint64(1) // The number of base-36 segments of the width, capped to fit a uint64
+	for n := 0; n < width && space <= math.MaxUint64/36; n++ {
+		space *= 36
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strconv.Itoa(i)))
+	start := h.Sum64() % space
+	if f.hashes == nil {
+		f.hashes = make(map[string]int)
+	}
+	for probe := uint64(0); probe < space; probe++ {
+		seg := strconv.FormatUint((start+probe)%space, 36)
+		if len(seg) < width {
+			seg = strings.Repeat("0", width-len(seg)) + seg
+		}
+		if isDigits(seg) {
+			continue
+		}
+		elemKey := key + indexDelimiter(f.options) + seg
+		if f.options.ArrayNotation == NotationBracket {
+			elemKey = key + "[" + seg + "]"
+		}
+		if _, taken := f.hashes[elemKey]; !taken {
+			f.hashes[elemKey] = i
+			return seg
+		}
+	}
+	f.fail(fmt.Errorf("%w: no free %d-character segment for index %d under %q", ErrIndexTooWide, width, i, key))
+	return strconv.Itoa(i)
+}
+
+// unhashIndices is a helper function that replaces the segments of key that end one of the
+// element keys in hashes with their indices, leaving other segments, such as map keys that look
+// like a hash, as they are.
+func unhashIndices(key string, hashes map[string]int, options Options) string {
+	var b strings.Builder
+	start := 0 // The start of the current part
+	for i := 0; i <= len(key); {
+		n := boundary(key[i:], options)
+		if i < len(key) && n == 0 {
+			i++
+			continue
+		}
+		// In bracket notation the element key ends after the closing bracket.
+		end := i
+		if i < len(key) && key[i] == ']' {
+			end++
+		}
+		if index, ok := hashes[key[:end]]; ok && start < i {
+			b.WriteString(strconv.Itoa(index))
+		} else {
+			b.WriteString(key[start:i])
+		}
+		if i == len(key) {
+			break
+		}
+		b.WriteString(key[i : i+n])
+		i += n
+		start = i
+	}
+	return b.String()
+}
+
+// boundary is a helper function that returns the length of the delimiter or bracket s starts
+// with, or 0 when it starts with neither.
+func boundary(s string, options Options) int {
+	for _, b := range []string{options.KeyDelimiter, indexDelimiter(options), "[", "]"} {
+		if b != "" && strings.HasPrefix(s, b) {
+			return len(b)
+		}
+	}
+	return 0
+}
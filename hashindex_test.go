@@ -0,0 +1,119 @@
+package goflat_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestIndexHashes(t *testing.T) {
+	elems := make([]string, 120)
+	for i := range elems {
+		elems[i] = fmt.Sprintf(`{"n": %d}`, i)
+	}
+	data := []byte(`{"items": [` + strings.Join(elems, ",") + `], "tags": ["a", "b"]}`)
+	var original interface{}
+	if err := json.Unmarshal(data, &original); err != nil {
+		t.Fatalf("Error decoding JSON: %v", err)
+	}
+
+	// Test case 1: Indices past the threshold are written in base 36
+	options := goflat.DefaultOptions()
+	options.MaxIndexDigits = 2
+	flattened, hashes, err := goflat.FlattenJSONWithIndexHashes(data, options)
+	if err != nil {
+		t.Fatalf(errorFlatteningJSON, err)
+	}
+	if len(hashes) != 20 || flattened["items.99.n"] != float64(99) {
+		t.Errorf("Unexpected hashes: %v", hashes)
+	}
+	for key := range flattened {
+		if seg := strings.Split(key, ".")[1]; len(seg) > 2 {
+			t.Errorf("Key segment too wide: %s", key)
+		}
+	}
+
+	// Test case 2: The mapping restores the original arrays
+	result, err := goflat.UnflattenJSONWithIndexHashes(flattened, hashes, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 3: Bracket notation round-trips as well
+	options.ArrayNotation = goflat.NotationBracket
+	flattened, hashes, err = goflat.FlattenJSONWithIndexHashes(data, options)
+	if err != nil {
+		t.Fatalf(errorFlatteningJSON, err)
+	}
+	result, err = goflat.UnflattenJSONWithIndexHashes(flattened, hashes, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 4: Colliding hashes fill every segment of the width, one more element fails
+	elems = make([]string, 3000)
+	for i := range elems {
+		elems[i] = strconv.Itoa(i)
+	}
+	options = goflat.DefaultOptions()
+	for n, width := range map[int]int{36: 1, 3000: 3} {
+		data = []byte(`{"ids": [` + strings.Join(elems[:n], ",") + `]}`)
+		if err := json.Unmarshal(data, &original); err != nil {
+			t.Fatalf("Error decoding JSON: %v", err)
+		}
+		options.MaxIndexDigits = width
+		flattened, hashes, err = goflat.FlattenJSONWithIndexHashes(data, options)
+		if err != nil {
+			t.Fatalf(errorFlatteningJSON, err)
+		}
+		for key := range flattened {
+			if seg := strings.TrimPrefix(key, "ids."); len(seg) > options.MaxIndexDigits {
+				t.Errorf("Key segment too wide: %s", key)
+			}
+		}
+		result, err = goflat.UnflattenJSONWithIndexHashes(flattened, hashes, options)
+		if err != nil {
+			t.Errorf(errorUnflatteningJSON, err)
+		}
+		if !reflect.DeepEqual(result, original) {
+			t.Errorf(errorUnflattenedJSONMismatch)
+		}
+	}
+	data = []byte(`{"ids": [` + strings.Join(elems[:37], ",") + `]}`)
+	options.MaxIndexDigits = 1
+	if _, _, err = goflat.FlattenJSONWithIndexHashes(data, options); !errors.Is(err, goflat.ErrIndexTooWide) {
+		t.Errorf("Expected ErrIndexTooWide, got %v", err)
+	}
+
+	// Test case 5: Map keys that look like a hash are not rewritten
+	data = []byte(`{"ids": [0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10], "meta": {"g": "kept"}, "g": 1}`)
+	if err := json.Unmarshal(data, &original); err != nil {
+		t.Fatalf("Error decoding JSON: %v", err)
+	}
+	flattened, hashes, err = goflat.FlattenJSONWithIndexHashes(data, options)
+	if err != nil {
+		t.Fatalf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(hashes, map[string]int{"ids.g": 10}) {
+		t.Errorf("Unexpected hashes: %v", hashes)
+	}
+	result, err = goflat.UnflattenJSONWithIndexHashes(flattened, hashes, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+}
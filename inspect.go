@@ -0,0 +1,238 @@
+package goflat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Fingerprint returns a stable SHA-256 hex digest of a flattened document. Keys are sorted and
+// each entry is hashed as its quoted key and JSON-encoded value, so the result does not depend
+// on map iteration order and two maps with the same content always hash identically.
+//
+// Example:
+//
+//	a := map[string]interface{}{"name": "John", "age": 30}
+//	b := map[string]interface{}{"age": 30, "name": "John"}
+//	fmt.Println(Fingerprint(a) == Fingerprint(b))
+//
+// Output:
+//
+//	true
+func Fingerprint(flattened map[string]interface{}) string {
+	keys := sortedKeys(flattened)
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(strconv.Quote(key)))
+		h.Write([]byte{'='})
+		h.Write(canonicalValue(flattened[key]))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sortedKeys is a helper function that returns the keys of flattened in byte order.
+func sortedKeys(flattened map[string]interface{}) []string {
+	keys := make([]string, 0, len(flattened))
+	for key := range flattened {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keyOrder is a helper function that returns the keys of flattened in the order of
+// options.KeySort, or in byte order when it is not set.
+func keyOrder(flattened map[string]interface{}, options Options) []string {
+	keys := sortedKeys(flattened)
+	if options.KeySort != nil {
+		sort.SliceStable(keys, func(i, j int) bool { return options.KeySort(keys[i], keys[j]) })
+	}
+	return keys
+}
+
+// canonicalValue is a helper function that encodes a leaf value for hashing. Values JSON cannot
+// represent fall back to their Go syntax representation.
+func canonicalValue(value interface{}) []byte {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return []byte(fmt.Sprintf("%#v", value))
+	}
+	return data
+}
+
+// LeavesByDepth groups the keys of a flattened document by their depth, the number of segments
+// they split into with options, so shallow keys can be handled first. Escaped delimiters do not
+// start new segments, and with NotationBracket every index counts as a segment. Each group is
+// sorted.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"name": "John", "address.city": "New York", "age": 30}
+//	fmt.Println(LeavesByDepth(flattened, DefaultOptions()))
+//
+// Output:
+//
+//	map[1:[age name] 2:[address.city]]
+func LeavesByDepth(flattened map[string]interface{}, options Options) map[int][]string {
+	depths := make(map[int][]string)
+	for _, key := range sortedKeys(flattened) {
+		depth := len(splitKey(key, options))
+		depths[depth] = append(depths[depth], key)
+	}
+	return depths
+}
+
+// NumericLeaves returns the numeric leaves of a flattened document converted to float64, skipping
+// every other value. float64, float32, int, int64 and json.Number leaves are numeric, the latter as
+// produced with Options.PreserveNumberText; json.Number values that do not parse are skipped.
+// options is the set the document was flattened with and is currently not consulted.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"name": "John", "age": 30.0, "score": json.Number("9.5")}
+//	fmt.Println(NumericLeaves(flattened, DefaultOptions()))
+//
+// Output:
+//
+//	map[age:30 score:9.5]
+func NumericLeaves(flattened map[string]interface{}, options Options) map[string]float64 {
+	numbers := make(map[string]float64)
+	for key, value := range flattened {
+		if f, ok := numericValue(value); ok {
+			numbers[key] = f
+		}
+	}
+	return numbers
+}
+
+// IsFlat reports whether data holds no nested containers, so flattening it would only copy it.
+// Maps, slices, arrays and pointers to them are containers, even when empty; []byte values are
+// leaves. An empty map is flat. IsFlat stops at the first container it finds.
+//
+// Example:
+//
+//	fmt.Println(IsFlat(map[string]interface{}{"name": "John", "address.city": "New York"}))
+//	fmt.Println(IsFlat(map[string]interface{}{"address": map[string]interface{}{}}))
+//
+// Output:
+//
+//	true
+//	false
+func IsFlat(data map[string]interface{}) bool {
+	for _, value := range data {
+		switch value.(type) {
+		case nil, string, bool, float64, json.Number, []byte:
+			continue
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+		if _, ok := byteSlice(value); ok {
+			continue
+		}
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Ptr {
+			rv = rv.Elem()
+		}
+		switch rv.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array:
+			return false
+		}
+	}
+	return true
+}
+
+// EstimateSize returns the approximate length in bytes of flattened encoded as a JSON object by
+// json.Marshal, without encoding it. Keys and strings are measured with their quotes and escapes,
+// and numbers, booleans and nulls with their JSON text; other values such as containers kept whole
+// fall back to being marshalled. The estimate is exact for most documents and only drifts for
+// strings with invalid UTF-8 or line separators. options is the set the document was flattened
+// with and is currently not consulted.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"name": "John", "address.city": "New York"}
+//	fmt.Println(EstimateSize(flattened, DefaultOptions()))
+//
+// Output:
+//
+//	41
+func EstimateSize(flattened map[string]interface{}, options Options) int {
+	size := 2
+	if len(flattened) > 0 {
+		size += len(flattened) - 1
+	}
+	for key, value := range flattened {
+		size += stringSize(key) + 1 + valueSize(value)
+	}
+	return size
+}
+
+// valueSize is a helper function that returns the length of value encoded as JSON.
+func valueSize(value interface{}) int {
+	var buf [64]byte
+	switch v := value.(type) {
+	case nil:
+		return 4
+	case bool:
+		if v {
+			return 4
+		}
+		return 5
+	case string:
+		return stringSize(v)
+	case json.Number:
+		return len(v)
+	case float64:
+		return floatSize(buf[:0], v, 64)
+	case float32:
+		return floatSize(buf[:0], float64(v), 32)
+	case int:
+		return len(strconv.AppendInt(buf[:0], int64(v), 10))
+	case int64:
+		return len(strconv.AppendInt(buf[:0], v, 10))
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// floatSize is a helper function that returns the length of f formatted the way encoding/json
+// formats floats, using buf as scratch space.
+func floatSize(buf []byte, f float64, bits int) int {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 && (bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21)) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, bits)
+	if format == 'e' {
+		// encoding/json shortens two-digit negative exponents, e.g. 1e-07 to 1e-7.
+		if n := len(buf); n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			return n - 1
+		}
+	}
+	return len(buf)
+}
+
+// stringSize is a helper function that returns the length of s encoded as a JSON string with
+// encoding/json's default HTML escaping.
+func stringSize(s string) int {
+	size := 2 + len(s)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\' || c == '\n' || c == '\r' || c == '\t' || c == '\b' || c == '\f':
+			size++
+		case c < 0x20 || c == '<' || c == '>' || c == '&':
+			size += 5
+		}
+	}
+	return size
+}
@@ -0,0 +1,164 @@
+package goflat_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFingerprint(t *testing.T) {
+	// Test case 1: Maps built in a different order have the same fingerprint
+	a := map[string]interface{}{}
+	b := map[string]interface{}{}
+	keys := []string{"name", "age", addressCityKey, hobbies0Key}
+	values := []interface{}{"John", 30, addressCity, hobbies0}
+	for i := range keys {
+		a[keys[i]] = values[i]
+		b[keys[len(keys)-1-i]] = values[len(keys)-1-i]
+	}
+	if goflat.Fingerprint(a) != goflat.Fingerprint(b) {
+		t.Errorf("Fingerprints of equal maps differ")
+	}
+	if len(goflat.Fingerprint(a)) != 64 {
+		t.Errorf("Fingerprint is not a SHA-256 hex digest: %s", goflat.Fingerprint(a))
+	}
+
+	// Test case 2: A single changed value changes the fingerprint
+	b["age"] = 31
+	if goflat.Fingerprint(a) == goflat.Fingerprint(b) {
+		t.Errorf("Fingerprints of different maps are equal")
+	}
+
+	// Test case 3: Moving a value to another key changes the fingerprint
+	c := map[string]interface{}{"a": "b"}
+	d := map[string]interface{}{"a=\"b\"": nil}
+	if goflat.Fingerprint(c) == goflat.Fingerprint(d) {
+		t.Errorf("Fingerprints of different maps are equal")
+	}
+}
+
+func TestLeavesByDepth(t *testing.T) {
+	// Test case 1: Keys of a mixed-depth document are grouped by segment count
+	flattened := map[string]interface{}{
+		"name":           "John",
+		"age":            30,
+		addressCityKey:   addressCity,
+		addressStreetKey: addressStreet,
+		hobbies0Key:      hobbies0,
+		aBCD:             true,
+	}
+	expected := map[int][]string{
+		1: {"age", "name"},
+		2: {addressCityKey, addressStreetKey, hobbies0Key},
+		4: {aBCD},
+	}
+	if result := goflat.LeavesByDepth(flattened, goflat.DefaultOptions()); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Depth groups do not match expected result: %v", result)
+	}
+
+	// Test case 2: Escaped delimiters stay within their segment and bracketed indices count
+	options := goflat.DefaultOptions()
+	options.EscapeChar = '\\'
+	options.ArrayNotation = goflat.NotationBracket
+	flattened = map[string]interface{}{
+		`a\.b\.c`:       1,
+		`a\.b.c`:        2,
+		"users[0].name": "Ann",
+	}
+	expected = map[int][]string{
+		1: {`a\.b\.c`},
+		2: {`a\.b.c`},
+		3: {"users[0].name"},
+	}
+	if result := goflat.LeavesByDepth(flattened, options); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Depth groups do not match expected result: %v", result)
+	}
+}
+
+func TestNumericLeaves(t *testing.T) {
+	// Test case 1: Numbers and json.Number values are kept, other leaves skipped
+	flattened := map[string]interface{}{
+		"name":         "John",
+		"age":          float64(30),
+		"score":        json.Number("9.5"),
+		"bad":          json.Number("x"),
+		"active":       true,
+		hobbies0Key:    hobbies0,
+		"address.zip":  nil,
+		"counts.total": 7,
+	}
+	expected := map[string]float64{
+		"age":          30,
+		"score":        9.5,
+		"counts.total": 7,
+	}
+	if result := goflat.NumericLeaves(flattened, goflat.DefaultOptions()); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Numeric leaves do not match expected result: %v", result)
+	}
+}
+
+func TestIsFlat(t *testing.T) {
+	// Test case 1: Maps of scalars, including empty ones, are flat
+	if !goflat.IsFlat(map[string]interface{}{}) {
+		t.Errorf("Expected an empty map to be flat")
+	}
+	flat := map[string]interface{}{"name": "John", addressCityKey: addressCity, "age": 30, "blob": []byte("x"), "nick": nil}
+	if !goflat.IsFlat(flat) {
+		t.Errorf("Expected a map of scalars to be flat")
+	}
+
+	// Test case 2: Nested maps, even empty ones, are not flat
+	if goflat.IsFlat(map[string]interface{}{"name": "John", "address": map[string]interface{}{}}) {
+		t.Errorf("Expected a map with a nested map not to be flat")
+	}
+
+	// Test case 3: Slices, typed or not, are not flat
+	if goflat.IsFlat(map[string]interface{}{"hobbies": []interface{}{hobbies0}}) {
+		t.Errorf("Expected a map with a slice not to be flat")
+	}
+	if goflat.IsFlat(map[string]interface{}{"tags": []string{"a"}}) {
+		t.Errorf("Expected a map with a typed slice not to be flat")
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	// Test case 1: The estimate of a flattened document matches its encoded length
+	data := []byte(`{"name": "John \"Johnny\" <Doe>", "age": 30, "score": 9.5, "tiny": 1e-7, "big": 1e21, "active": true, "nick": null, "address": {"street": "123 Main St\n", "city": "New York"}, "hobbies": ["reading", "gaming"]}`)
+	options := goflat.DefaultOptions()
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Error marshalling flattened map: %v", err)
+	}
+	if size := goflat.EstimateSize(result, options); size != len(encoded) {
+		t.Errorf("Estimated size %d does not match encoded length %d", size, len(encoded))
+	}
+
+	// Test case 2: Go values and containers kept whole stay within a few percent
+	flattened := map[string]interface{}{
+		"count":   42,
+		"total":   int64(-1234567),
+		"ratio":   float32(0.25),
+		"unicode": "héllo wörld  ",
+		"tags":    []interface{}{"a", "b"},
+		"meta":    map[string]interface{}{"x": 1},
+	}
+	encoded, err = json.Marshal(flattened)
+	if err != nil {
+		t.Fatalf("Error marshalling flattened map: %v", err)
+	}
+	size := goflat.EstimateSize(flattened, options)
+	if diff := size - len(encoded); diff < -len(encoded)/20 || diff > len(encoded)/20 {
+		t.Errorf("Estimated size %d is not within 5%% of encoded length %d", size, len(encoded))
+	}
+
+	// Test case 3: An empty map is two bytes
+	if size := goflat.EstimateSize(map[string]interface{}{}, options); size != 2 {
+		t.Errorf("Estimated size of an empty map is %d, expected 2", size)
+	}
+}
@@ -0,0 +1,84 @@
+package goflat
+
+// FlattenJSONC flattens a JSON object that may contain "//" line comments, "/* */" block comments
+// and trailing commas, as found in many configuration files. Comment markers inside string
+// literals are left untouched.
+//
+// Example:
+//
+//	data := []byte(`{
+//		// The user name
+//		"name": "John", /* inline */
+//		"tags": ["a", "b",],
+//	}`)
+//	flattened, err := FlattenJSONC(data, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(flattened)
+//
+// Output:
+//
+//	map[name:John tags.0:a tags.1:b]
+func FlattenJSONC(data []byte, options Options) (map[string]interface{}, error) {
+	return FlattenJSON(stripJSONC(data), options)
+}
+
+// stripJSONC is a helper function that replaces comments and trailing commas with spaces,
+// keeping the byte offsets of everything else unchanged. Only a comma that follows a value is a
+// trailing comma, so "[,]" and "{,}" stay invalid.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	lastComma := -1     // Offset of a comma not yet followed by a significant character
+	afterValue := false // Whether the last significant character ends a value
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			lastComma = -1
+			afterValue = true
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; i < len(out) && !(out[i] == '*' && i+1 < len(out) && out[i+1] == '/'); i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			if i < len(out) {
+				out[i], out[i+1] = ' ', ' '
+				i++
+			}
+		case c == ',':
+			lastComma = -1
+			if afterValue {
+				lastComma = i
+			}
+			afterValue = false
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+			afterValue = true
+		case c == '{' || c == '[' || c == ':':
+			lastComma = -1
+			afterValue = false
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			lastComma = -1
+			afterValue = true
+		}
+	}
+	return out
+}
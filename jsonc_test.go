@@ -0,0 +1,50 @@
+package goflat_test
+
+import (
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFlattenJSONC(t *testing.T) {
+	// Test case 1: Line comments, block comments and trailing commas
+	data := []byte(`{
+	// The user name
+	"name": "John", /* inline
+	   block */
+	"address": {"city": "New York",},
+	"hobbies": ["reading", "gaming", /* last */ ],
+	"url": "https://example.com/*path*/", // comment-like substrings stay
+	"quote": "a \"// b\"",
+}`)
+	options := goflat.DefaultOptions()
+	expected := map[string]interface{}{
+		"name":         "John",
+		addressCityKey: addressCity,
+		hobbies0Key:    hobbies0,
+		hobbies1Key:    hobbies1,
+		"url":          "https://example.com/*path*/",
+		"quote":        `a "// b"`,
+	}
+	result, err := goflat.FlattenJSONC(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Invalid JSON is still reported
+	_, err = goflat.FlattenJSONC([]byte(`{"name": }`), options)
+	if err == nil {
+		t.Errorf("Expected error when unmarshalling invalid JSON")
+	}
+
+	// Test case 3: Commas that do not follow a value are not trailing commas
+	for _, data := range []string{`{,}`, `{"a": [,]}`, `{"a": [1,,]}`, `{"a": 1,,}`, `{"a": , }`} {
+		if _, err = goflat.FlattenJSONC([]byte(data), options); err == nil {
+			t.Errorf("Expected error for %s", data)
+		}
+	}
+}
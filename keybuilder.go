@@ -0,0 +1,65 @@
+package goflat
+
+import "strconv"
+
+// KeyBuilder builds flattened keys, see Options.KeyBuilder. Child returns the key of the map
+// member key under parent, where an empty parent stands for the top level, and Index returns the
+// key of the array element i under parent.
+type KeyBuilder interface {
+	Child(parent string, key string) string
+	Index(parent string, i int) string
+}
+
+// KeyParser splits a flattened key built by a KeyBuilder back into its segments, in the form
+// PathSplit returns them: map keys unescaped and array indices as "[n]". A KeyBuilder that also
+// implements KeyParser is used by UnflattenJSON, PathSplit and the other parsing functions.
+type KeyParser interface {
+	Split(key string) []string
+}
+
+// NewKeyBuilder returns a KeyBuilder and KeyParser implementing the key scheme that
+// options.KeyDelimiter, ArrayNotation, IndexDelimiter, EscapeChar and JSONPointer describe: dot
+// keys with DefaultOptions, bracket keys with NotationBracket and JSON Pointers with
+// JSONPointerOptions. It is a starting point for custom builders that only change part of a
+// scheme.
+//
+// Example:
+//
+//	options := DefaultOptions()
+//	options.ArrayNotation = NotationBracket
+//	kb := NewKeyBuilder(options)
+//	fmt.Println(kb.Child(kb.Index("users", 0), "name"))
+//
+// Output:
+//
+//	users[0].name
+func NewKeyBuilder(options Options) KeyBuilder {
+	options.KeyBuilder = nil
+	return optionsKeyBuilder{options: options}
+}
+
+// optionsKeyBuilder is the KeyBuilder returned by NewKeyBuilder.
+type optionsKeyBuilder struct {
+	options Options
+}
+
+// Child returns the key of the map member key under parent.
+func (b optionsKeyBuilder) Child(parent string, key string) string {
+	if parent == "" && !b.options.JSONPointer {
+		return escapeSegment(key, b.options)
+	}
+	return parent + b.options.KeyDelimiter + escapeSegment(key, b.options)
+}
+
+// Index returns the key of the array element i under parent.
+func (b optionsKeyBuilder) Index(parent string, i int) string {
+	if b.options.ArrayNotation == NotationBracket {
+		return parent + "[" + strconv.Itoa(i) + "]"
+	}
+	return parent + indexDelimiter(b.options) + strconv.Itoa(i)
+}
+
+// Split splits key into its segments as PathSplit does.
+func (b optionsKeyBuilder) Split(key string) []string {
+	return PathSplit(key, b.options)
+}
@@ -0,0 +1,97 @@
+package goflat_test
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+// arrowKeys builds keys such as "users(0)->name", writing "->" between map keys and "(n)" after
+// array parents.
+type arrowKeys struct{}
+
+func (arrowKeys) Child(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "->" + key
+}
+
+func (arrowKeys) Index(parent string, i int) string {
+	return parent + "(" + strconv.Itoa(i) + ")"
+}
+
+func (arrowKeys) Split(key string) []string {
+	var segments []string
+	for _, part := range strings.Split(key, "->") {
+		for {
+			open := strings.IndexByte(part, '(')
+			if open < 0 {
+				segments = append(segments, part)
+				break
+			}
+			if open > 0 {
+				segments = append(segments, part[:open])
+			}
+			end := strings.IndexByte(part, ')')
+			segments = append(segments, "["+part[open+1:end]+"]")
+			part = part[end+1:]
+			if part == "" {
+				break
+			}
+		}
+	}
+	return segments
+}
+
+func TestKeyBuilder(t *testing.T) {
+	data := []byte(`{"name": "John", "users": [{"name": "Ann", "tags": ["a", "b"]}], "m": [[1, 2]]}`)
+
+	// Test case 1: A custom builder produces its own scheme
+	options := goflat.DefaultOptions()
+	options.KeyBuilder = arrowKeys{}
+	expected := map[string]interface{}{
+		"name":              "John",
+		"users(0)->name":    "Ann",
+		"users(0)->tags(0)": "a",
+		"users(0)->tags(1)": "b",
+		"m(0)(0)":           float64(1),
+		"m(0)(1)":           float64(2),
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: The matching parser round-trips the document
+	assertRoundTrip(t, data, options)
+	if split := goflat.PathSplit("users(0)->tags(1)", options); !reflect.DeepEqual(split, []string{"users", "[0]", "tags", "[1]"}) {
+		t.Errorf("Split segments do not match: %v", split)
+	}
+
+	// Test case 3: The built-in builders match the option-driven schemes
+	bracket := goflat.DefaultOptions()
+	bracket.ArrayNotation = goflat.NotationBracket
+	for _, base := range []goflat.Options{goflat.DefaultOptions(), bracket, goflat.JSONPointerOptions()} {
+		want, err := goflat.FlattenJSON(data, base)
+		if err != nil {
+			t.Errorf(errorFlatteningJSON, err)
+		}
+		built := base
+		built.KeyBuilder = goflat.NewKeyBuilder(base)
+		got, err := goflat.FlattenJSON(data, built)
+		if err != nil {
+			t.Errorf(errorFlatteningJSON, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Built keys %v do not match %v", got, want)
+		}
+		assertRoundTrip(t, data, built)
+	}
+}
@@ -0,0 +1,91 @@
+package goflat
+
+// SegmentKind tells whether a segment of a flattened key is a map key or an array index.
+type SegmentKind int
+
+const (
+	SegmentKey   SegmentKind = iota // The segment is a map key
+	SegmentIndex                    // The segment is an array index
+)
+
+// SegmentKinds holds the kind of every segment of a flattened key, in order.
+type SegmentKinds []SegmentKind
+
+// FlattenJSONWithKinds flattens a JSON object like FlattenJSON and also returns, for every
+// flattened key, the kinds of its segments, telling array indices apart from numeric map keys
+// without adding entries to the output. UnflattenJSONWithKinds uses them to rebuild exactly the
+// original arrays. Keys rewritten after the walk by TrimPrefix, PrefixFromPath or SlugifyKeys,
+// and keys added by Derive, have no kinds.
+//
+// Example:
+//
+//	data := []byte(`{"hobbies": ["reading"], "codes": {"0": "zero"}}`)
+//	_, kinds, err := FlattenJSONWithKinds(data, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(kinds["hobbies.0"], kinds["codes.0"])
+//
+// Output:
+//
+//	[0 1] [0 0]
+func FlattenJSONWithKinds(data []byte, options Options) (map[string]interface{}, map[string]SegmentKinds, error) {
+	decoded, err := jsonDecoder(options)(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := newFlattener(options)
+	f.kinds = make(map[string]SegmentKinds)
+	f.run(decoded)
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	for key := range f.kinds {
+		if _, ok := f.flattened[key]; !ok {
+			delete(f.kinds, key)
+		}
+	}
+	return f.flattened, f.kinds, nil
+}
+
+// UnflattenJSONWithKinds unflattens like UnflattenJSON, but rebuilds as arrays exactly the nodes
+// whose member segments kinds marks as SegmentIndex, so numeric-keyed maps stay maps. Keys missing
+// from kinds are read as map keys only.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"hobbies.0": "reading", "codes.0": "zero"}
+//	kinds := map[string]SegmentKinds{
+//		"hobbies.0": {SegmentKey, SegmentIndex},
+//		"codes.0":   {SegmentKey, SegmentKey},
+//	}
+//	unflattened, err := UnflattenJSONWithKinds(flattened, kinds, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(unflattened)
+//
+// Output:
+//
+//	map[codes:map[0:zero] hobbies:[reading]]
+func UnflattenJSONWithKinds(flattened map[string]interface{}, kinds map[string]SegmentKinds, options Options) (interface{}, error) {
+	u := newUnflattener(options)
+	u.exact = true
+	for key, value := range flattened {
+		keys := splitKeys(key, options)
+		for i, kind := range kinds[key] {
+			if kind == SegmentIndex && i < len(keys) {
+				u.recordArray(keys[:i])
+			}
+		}
+		if err := u.add(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if !u.needsPromotion() {
+		return u.result, nil
+	}
+	return u.promoteMembers(u.result), nil
+}
@@ -0,0 +1,43 @@
+package goflat_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFlattenJSONWithKinds(t *testing.T) {
+	data := []byte(`{"hobbies": ["reading", "gaming"], "codes": {"0": "zero", "1": "one"}, "matrix": [{"0": [true]}]}`)
+
+	// Test case 1: Array indices and numeric map keys get different kinds
+	flattened, kinds, err := goflat.FlattenJSONWithKinds(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	key, index := goflat.SegmentKey, goflat.SegmentIndex
+	expected := map[string]goflat.SegmentKinds{
+		hobbies0Key:    {key, index},
+		hobbies1Key:    {key, index},
+		"codes.0":      {key, key},
+		"codes.1":      {key, key},
+		"matrix.0.0.0": {key, index, key, index},
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Segment kinds do not match expected result: %v", kinds)
+	}
+
+	// Test case 2: The kinds rebuild arrays and keep numeric-keyed maps
+	result, err := goflat.UnflattenJSONWithKinds(flattened, kinds, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	var original map[string]interface{}
+	if err := json.Unmarshal(data, &original); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+}
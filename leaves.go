@@ -0,0 +1,31 @@
+//go:build go1.23
+
+package goflat
+
+import (
+	"errors"
+	"iter"
+)
+
+// errStopLeaves is returned to WalkLeaves when the loop ranging over Leaves breaks.
+var errStopLeaves = errors.New("goflat: leaves iteration stopped")
+
+// Leaves returns an iterator over the flattened entries of data, for use with range:
+//
+//	for key, value := range Leaves(data, DefaultOptions()) {
+//		fmt.Println(key, value)
+//	}
+//
+// The walk is lazy like WalkLeaves, with the same ordering rules, and stops as soon as the loop
+// breaks. Like FlattenMap, Leaves cannot report errors: options that fail only end the iteration
+// early. Use WalkLeaves when such options are set.
+func Leaves(data map[string]interface{}, options Options) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		_ = WalkLeaves(data, options, func(key string, value interface{}) error {
+			if !yield(key, value) {
+				return errStopLeaves
+			}
+			return nil
+		})
+	}
+}
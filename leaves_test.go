@@ -0,0 +1,85 @@
+//go:build go1.23
+
+package goflat_test
+
+import (
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestLeaves(t *testing.T) {
+	data := map[string]interface{}{
+		"name":    "John",
+		"address": map[string]interface{}{"city": addressCity, "street": addressStreet},
+		"hobbies": []interface{}{hobbies0, hobbies1},
+	}
+
+	// Test case 1: Iterating fully yields every entry
+	result := make(map[string]interface{})
+	for key, value := range goflat.Leaves(data, goflat.DefaultOptions()) {
+		result[key] = value
+	}
+	if !reflect.DeepEqual(result, goflat.FlattenMap(data, goflat.DefaultOptions())) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 2: Breaking early stops the walk
+	visited := 0
+	options := goflat.DefaultOptions()
+	options.LeafVisitor = func(path []string, value interface{}) {
+		visited++
+	}
+	for range goflat.Leaves(data, options) {
+		break
+	}
+	if visited != 1 {
+		t.Errorf("Expected the walk to stop after 1 leaf, visited %d", visited)
+	}
+
+	// Test case 3: Sorted iteration with SortKeys
+	options = goflat.DefaultOptions()
+	options.SortKeys = true
+	var keys []string
+	for key := range goflat.Leaves(data, options) {
+		keys = append(keys, key)
+	}
+	expected := []string{addressCityKey, addressStreetKey, hobbies0Key, hobbies1Key, "name"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Keys do not match expected order: %v", keys)
+	}
+
+	// Test case 4: Breaking early is safe when one leaf emits several entries
+	scores := map[string]interface{}{"scores": []interface{}{1.0, 2.0}}
+	expand := goflat.DefaultOptions()
+	expand.ExpandLeaf = func(key string, value interface{}) map[string]interface{} {
+		return map[string]interface{}{"x": value, "y": value}
+	}
+	aggregates := goflat.DefaultOptions()
+	aggregates.EmitNumericAggregates = true
+	tagged := goflat.DefaultOptions()
+	tagged.TagArrays = true
+	tagged.EmitArrayLength = true
+	truncated := goflat.DefaultOptions()
+	truncated.MaxStringLength = 6
+	truncated.StringLengthSidecar = true
+	for _, tc := range []struct {
+		data    map[string]interface{}
+		options goflat.Options
+	}{
+		{scores, expand},
+		{scores, aggregates},
+		{scores, tagged},
+		{map[string]interface{}{"bio": "a very long biography"}, truncated},
+	} {
+		seen := 0
+		for range goflat.Leaves(tc.data, tc.options) {
+			seen++
+			break
+		}
+		if seen != 1 {
+			t.Errorf("Expected 1 entry before the break, got %d", seen)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package goflat
+
+// FlattenJSONWithLosses flattens a JSON object like FlattenJSON and also returns, by final key,
+// the values that were overwritten rather than kept, so lossy options can be debugged without
+// failing the call. Leaves whose keys meet after KeyCase or NormalizeUnicodeKeys, members merged
+// by TrimSpaceLastWins, entries replaced per DeriveOverwrite or RenameRegexLastWins, and entries
+// meeting when TrimPrefix, PrefixFromPath or KindPrefixKeys rewrite keys are all recorded. Which
+// of two colliding leaves is kept may follow map iteration order. The losses map is empty when
+// nothing was lost.
+//
+// Example:
+//
+//	data := []byte(`{"Name": "John", "address": {"city": "New York"}}`)
+//	options := DefaultOptions()
+//	options.KeyCase = CaseLower
+//	flattened, losses, err := FlattenJSONWithLosses(data, options)
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(flattened, len(losses))
+//
+// Output:
+//
+//	map[address.city:New York name:John] 0
+func FlattenJSONWithLosses(data []byte, options Options) (map[string]interface{}, map[string][]interface{}, error) {
+	decoded, err := jsonDecoder(options)(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := newFlattener(options)
+	f.losses = make(map[string][]interface{})
+	f.run(decoded)
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	return f.flattened, f.losses, nil
+}
@@ -0,0 +1,55 @@
+package goflat_test
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFlattenJSONWithLosses(t *testing.T) {
+	// Test case 1: Keys meeting after lowercasing keep one value and record the other
+	data := []byte(`{"Name": "John", "name": "Johnny", "address": {"City": "New York", "city": "NYC", "zip": "10001"}}`)
+	options := goflat.DefaultOptions()
+	options.KeyCase = goflat.CaseLower
+	result, losses, err := goflat.FlattenJSONWithLosses(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if len(result) != 3 || result["address.zip"] != "10001" || len(losses) != 2 {
+		t.Errorf("Unexpected result %v with losses %v", result, losses)
+	}
+	for key, values := range map[string][]interface{}{"name": {"John", "Johnny"}, addressCityKey: {addressCity, "NYC"}} {
+		if len(losses[key]) != 1 {
+			t.Errorf("Expected one lost value under %q, got %v", key, losses[key])
+			continue
+		}
+		kept := []interface{}{result[key], losses[key][0]}
+		if !reflect.DeepEqual(kept, values) && !reflect.DeepEqual(kept, []interface{}{values[1], values[0]}) {
+			t.Errorf("Kept and lost values under %q do not match: %v", key, kept)
+		}
+	}
+
+	// Test case 2: Entries dropped by RenameRegexLastWins are recorded under the new key
+	options = goflat.DefaultOptions()
+	options.RenameRegex = []goflat.RegexRename{{Pattern: regexp.MustCompile(`^(home|work)\.`), Replace: "contact."}}
+	options.RenameRegexLastWins = true
+	result, losses, err = goflat.FlattenJSONWithLosses([]byte(`{"home": {"phone": "1"}, "work": {"phone": "2"}}`), options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"contact.phone": "2"}) ||
+		!reflect.DeepEqual(losses, map[string][]interface{}{"contact.phone": {"1"}}) {
+		t.Errorf("Unexpected result %v with losses %v", result, losses)
+	}
+
+	// Test case 3: Nothing is recorded when nothing is overwritten
+	result, losses, err = goflat.FlattenJSONWithLosses(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if len(result) != 5 || len(losses) != 0 {
+		t.Errorf("Unexpected result %v with losses %v", result, losses)
+	}
+}
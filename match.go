@@ -0,0 +1,41 @@
+package goflat
+
+// matchGlob is a helper function that reports whether key matches pattern, where "*" matches
+// any run of characters (delimiters included) and "?" matches exactly one character.
+func matchGlob(pattern, key string) bool {
+	p := []rune(pattern)
+	k := []rune(key)
+	// Classic wildcard matching with backtracking to the most recent "*".
+	pi, ki := 0, 0
+	star, match := -1, 0
+	for ki < len(k) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == k[ki]):
+			pi++
+			ki++
+		case pi < len(p) && p[pi] == '*':
+			star, match = pi, ki
+			pi++
+		case star >= 0:
+			pi = star + 1
+			match++
+			ki = match
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// matchAny is a helper function that reports whether key matches at least one of patterns.
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
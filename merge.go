@@ -0,0 +1,146 @@
+package goflat
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ApplyFlat applies the flattened overrides in flat onto a copy of the nested document base and
+// returns the merged document. Missing intermediate nodes are created as maps, and array
+// elements of base can be addressed by index (an index equal to the length appends). Keys are
+// applied in NaturalKeySort order, so several indices past the end append in index order.
+// base itself is never modified. ErrKeyConflict is returned when a path runs through a leaf
+// or would replace a nested value with a scalar.
+//
+// Example:
+//
+//	base := map[string]interface{}{
+//		"name":    "John",
+//		"address": map[string]interface{}{"city": "New York"},
+//	}
+//	flat := map[string]interface{}{
+//		"address.city": "Boston",
+//		"address.zip":  "02108",
+//	}
+//	merged, err := ApplyFlat(base, flat, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(merged)
+//
+// Output:
+//
+//	map[address:map[city:Boston zip:02108] name:John]
+func ApplyFlat(base map[string]interface{}, flat map[string]interface{}, options Options) (map[string]interface{}, error) {
+	result := DeepCopy(base)
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return NaturalKeySort(keys[i], keys[j]) })
+	for _, key := range keys {
+		if _, err := applyAt(result, splitKeys(key, options), flat[key]); err != nil {
+			return nil, fmt.Errorf("%w: %q", err, key)
+		}
+	}
+	return result, nil
+}
+
+// applyAt is a helper function that sets value at keys inside node, descending through both
+// maps and slices. Slices may grow on append, so the updated node is returned for the caller to store.
+func applyAt(node interface{}, keys []string, value interface{}) (interface{}, error) {
+	key := keys[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(keys) == 1 {
+			if isContainer(n[key]) && !isContainer(value) {
+				return nil, ErrKeyConflict
+			}
+			n[key] = value
+			return n, nil
+		}
+		child, ok := n[key]
+		if !ok {
+			child = make(map[string]interface{})
+		}
+		child, err := applyAt(child, keys[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[key] = child
+		return n, nil
+	case []interface{}:
+		i, ok := parseIndex(key)
+		if !ok || i > len(n) {
+			return nil, ErrKeyConflict
+		}
+		if i == len(n) {
+			n = append(n, nil)
+			if len(keys) > 1 {
+				n[i] = make(map[string]interface{})
+			}
+		}
+		if len(keys) == 1 {
+			if isContainer(n[i]) && !isContainer(value) {
+				return nil, ErrKeyConflict
+			}
+			n[i] = value
+			return n, nil
+		}
+		child, err := applyAt(n[i], keys[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+		return n, nil
+	default:
+		return nil, ErrKeyConflict
+	}
+}
+
+// isContainer is a helper function that reports whether value is a nested map or slice.
+func isContainer(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// DeepCopy returns an independent copy of a nested document. Nested map[string]interface{} and
+// []interface{} values are copied recursively while scalar leaves, which are immutable, are shared.
+// Other values, such as typed slices, are shared as they are.
+//
+// Example:
+//
+//	original := map[string]interface{}{"tags": []interface{}{"a"}}
+//	copied := DeepCopy(original)
+//	copied["tags"].([]interface{})[0] = "b"
+//	fmt.Println(original, copied)
+//
+// Output:
+//
+//	map[tags:[a]] map[tags:[b]]
+func DeepCopy(data map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		result[key] = deepCopyValue(value)
+	}
+	return result
+}
+
+// deepCopyValue is a helper function that copies a single nested value.
+func deepCopyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return DeepCopy(v)
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, val := range v {
+			arr[i] = deepCopyValue(val)
+		}
+		return arr
+	}
+	return value
+}
@@ -0,0 +1,119 @@
+package goflat_test
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestApplyFlat(t *testing.T) {
+	// Test case 1: Overriding an existing leaf and adding a new deep path
+	base := map[string]interface{}{
+		"name": "John",
+		"address": map[string]interface{}{
+			"city": addressCity,
+		},
+		"hobbies": []interface{}{hobbies0, hobbies1},
+	}
+	flat := map[string]interface{}{
+		addressCityKey:  "Boston",
+		"a.b.c":         "new",
+		hobbies1Key:     "hiking",
+		"hobbies.2":     "chess",
+		"address.zip.5": "02108",
+	}
+	expected := map[string]interface{}{
+		"name": "John",
+		"address": map[string]interface{}{
+			"city": "Boston",
+			"zip": map[string]interface{}{
+				"5": "02108",
+			},
+		},
+		"hobbies": []interface{}{hobbies0, "hiking", "chess"},
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{
+				"c": "new",
+			},
+		},
+	}
+	options := goflat.DefaultOptions()
+	result, err := goflat.ApplyFlat(base, flat, options)
+	if err != nil {
+		t.Errorf("Error applying flat map: %+v", err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Merged document does not match expected result: %v", result)
+	}
+
+	// Test case 2: The base document is not mutated
+	if base["address"].(map[string]interface{})["city"] != addressCity || len(base["hobbies"].([]interface{})) != 2 {
+		t.Errorf("Base document was mutated: %v", base)
+	}
+
+	// Test case 3: Descending through a scalar is a conflict
+	_, err = goflat.ApplyFlat(base, map[string]interface{}{"name.first": "John"}, options)
+	if !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+
+	// Test case 4: Replacing a container with a scalar is a conflict
+	_, err = goflat.ApplyFlat(base, map[string]interface{}{"address": "unknown"}, options)
+	if !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+	// Test case 5: Several indices past the end append in index order
+	arr := map[string]interface{}{"arr": []interface{}{"a", "b"}}
+	flat = map[string]interface{}{}
+	for i := 2; i <= 11; i++ {
+		flat["arr."+strconv.Itoa(i)] = i
+	}
+	flat["arr.3.x"] = nil
+	delete(flat, "arr.3")
+	for run := 0; run < 20; run++ {
+		result, err = goflat.ApplyFlat(arr, flat, options)
+		if err != nil {
+			t.Fatalf("Error applying flat map: %+v", err)
+		}
+		got := result["arr"].([]interface{})
+		if len(got) != 12 || got[2] != 2 || got[11] != 11 || !reflect.DeepEqual(got[3], map[string]interface{}{"x": nil}) {
+			t.Fatalf("Appended array does not match expected result: %v", got)
+		}
+	}
+}
+
+func TestDeepCopy(t *testing.T) {
+	// Test case 1: The copy equals the original
+	original := map[string]interface{}{
+		"name": "John",
+		"address": map[string]interface{}{
+			"city": addressCity,
+		},
+		"matrix": []interface{}{
+			[]interface{}{1, 2},
+			map[string]interface{}{"k": "v"},
+		},
+	}
+	copied := goflat.DeepCopy(original)
+	if !reflect.DeepEqual(copied, original) {
+		t.Errorf("Copy does not match the original")
+	}
+
+	// Test case 2: Mutating the copy's nested maps and slices leaves the original untouched
+	copied["address"].(map[string]interface{})["city"] = "Boston"
+	copied["matrix"].([]interface{})[0].([]interface{})[1] = 3
+	copied["matrix"].([]interface{})[1].(map[string]interface{})["k"] = "w"
+	copied["name"] = "Jane"
+	if original["address"].(map[string]interface{})["city"] != addressCity {
+		t.Errorf("Mutating a copied map changed the original")
+	}
+	if original["matrix"].([]interface{})[0].([]interface{})[1] != 2 {
+		t.Errorf("Mutating a copied slice changed the original")
+	}
+	if original["matrix"].([]interface{})[1].(map[string]interface{})["k"] != "v" || original["name"] != "John" {
+		t.Errorf("Mutating the copy changed the original")
+	}
+}
@@ -0,0 +1,66 @@
+package goflat_test
+
+import (
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestNormalizeUnicodeKeys(t *testing.T) {
+	// Test case 1: Composed and decomposed spellings of a key merge
+	data := []byte(`{"caf\u00e9": {"name": "John"}, "cafe\u0301": {"age": 30}}`)
+	options := goflat.DefaultOptions()
+	options.NormalizeUnicodeKeys = true
+	expected := map[string]interface{}{
+		"caf\u00e9.name": "John",
+		"caf\u00e9.age":  float64(30),
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Stacked marks, Hangul and ASCII keys
+	data = []byte(`{"a\u0323\u0302": 1, "\u1100\u1161\u11a8": 2, "plain": 3}`)
+	expected = map[string]interface{}{
+		"\u1ead": float64(1),
+		"\uac01": float64(2),
+		"plain":  float64(3),
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 3: Singleton mappings and marks out of canonical order merge too
+	data = []byte(`{"\u212b": 1, "\u00c5x": 2, "a\u0302\u0323": 3}`)
+	expected = map[string]interface{}{
+		"\u00c5":  float64(1),
+		"\u00c5x": float64(2),
+		"\u1ead":  float64(3),
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 4: Without the option the spellings stay distinct
+	data = []byte(`{"caf\u00e9": 1, "cafe\u0301": 2}`)
+	result, err = goflat.FlattenJSON(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if len(result) != 2 {
+		t.Errorf("Expected 2 distinct keys, got %v", result)
+	}
+}
@@ -0,0 +1,197 @@
+package goflat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Pair is a single flattened key and its value.
+type Pair struct {
+	Key   string
+	Value interface{}
+}
+
+// OrderedFlatInput is a flattened document whose entries keep the order they were produced in.
+type OrderedFlatInput []Pair
+
+// UnflattenOrderedInput unflattens pairs like UnflattenJSON and encodes the result as JSON.
+// Object members appear in the order their first key appears in pairs, which a map-based
+// unflatten cannot preserve.
+//
+// Example:
+//
+//	pairs := []Pair{
+//		{Key: "name", Value: "John"},
+//		{Key: "address.state", Value: "NY"},
+//		{Key: "address.city", Value: "New York"},
+//	}
+//	data, err := UnflattenOrderedInput(pairs, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(string(data))
+//
+// Output:
+//
+//	{"name":"John","address":{"state":"NY","city":"New York"}}
+func UnflattenOrderedInput(pairs []Pair, options Options) ([]byte, error) {
+	u := &unflattener{options: options}
+	root := newOrderedNode()
+	for _, pair := range pairs {
+		keys := splitKeys(pair.Key, options)
+		if options.TagArrays && keys[len(keys)-1] == ArrayMarkerKey && pair.Value == ArrayMarkerValue {
+			if u.arrays == nil {
+				u.arrays = make(map[string]bool)
+			}
+			u.arrays[pathKey(keys[:len(keys)-1])] = true
+			if _, err := root.node(keys[:len(keys)-1]); err != nil {
+				return nil, fmt.Errorf("%w: %q", err, pair.Key)
+			}
+			continue
+		}
+		if err := root.set(keys, pair.Value); err != nil {
+			return nil, fmt.Errorf("%w: %q", err, pair.Key)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := u.encodeOrdered(&buf, root, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnflattenJSONOrdered unflattens flattened like UnflattenOrderedInput, taking the order of its
+// entries from order, such as the one returned by FlattenJSONOrdered. Keys of flattened missing
+// from order follow in sorted order, and keys of order missing from flattened are ignored.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"name": "John", "address.state": "NY", "address.city": "New York"}
+//	order := []string{"name", "address.state", "address.city"}
+//	data, err := UnflattenJSONOrdered(flattened, order, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(string(data))
+//
+// Output:
+//
+//	{"name":"John","address":{"state":"NY","city":"New York"}}
+func UnflattenJSONOrdered(flattened map[string]interface{}, order []string, options Options) ([]byte, error) {
+	pairs := make([]Pair, 0, len(flattened))
+	seen := make(map[string]bool, len(flattened))
+	for _, key := range order {
+		if value, ok := flattened[key]; ok && !seen[key] {
+			pairs = append(pairs, Pair{Key: key, Value: value})
+			seen[key] = true
+		}
+	}
+	for _, key := range sortedKeys(flattened) {
+		if !seen[key] {
+			pairs = append(pairs, Pair{Key: key, Value: flattened[key]})
+		}
+	}
+	return UnflattenOrderedInput(pairs, options)
+}
+
+// orderedNode is a nested object that remembers the order its members were added in.
+type orderedNode struct {
+	keys    []string
+	members map[string]interface{} // Either *orderedNode or a leaf value
+}
+
+// newOrderedNode is a helper function that creates an empty orderedNode.
+func newOrderedNode() *orderedNode {
+	return &orderedNode{members: make(map[string]interface{})}
+}
+
+// node is a helper function that returns the nested node at keys, creating missing ones.
+func (n *orderedNode) node(keys []string) (*orderedNode, error) {
+	for _, key := range keys {
+		child, ok := n.members[key]
+		if !ok {
+			child = newOrderedNode()
+			n.members[key] = child
+			n.keys = append(n.keys, key)
+		}
+		next, ok := child.(*orderedNode)
+		if !ok {
+			return nil, ErrKeyConflict
+		}
+		n = next
+	}
+	return n, nil
+}
+
+// set is a helper function that sets a leaf value at keys, mirroring setValue.
+func (n *orderedNode) set(keys []string, value interface{}) error {
+	parent, err := n.node(keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	lastKey := keys[len(keys)-1]
+	existing, ok := parent.members[lastKey]
+	if _, isNode := existing.(*orderedNode); isNode {
+		return ErrKeyConflict
+	}
+	if !ok {
+		parent.keys = append(parent.keys, lastKey)
+	}
+	parent.members[lastKey] = value
+	return nil
+}
+
+// encodeOrdered is a helper function that writes node as JSON, turning nodes below the root that
+// qualify as arrays into JSON arrays. The root stays an object, as in UnflattenJSON.
+func (u *unflattener) encodeOrdered(buf *bytes.Buffer, node *orderedNode, keys []string) error {
+	if len(keys) > 0 && u.isArray(keys, node.keys) {
+		buf.WriteByte('[')
+		for i := range node.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key := strconv.Itoa(i)
+			if err := u.encodeOrderedValue(buf, node.members[key], append(keys[:len(keys):len(keys)], key)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	buf.WriteByte('{')
+	for i, key := range node.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		if err := u.encodeOrderedValue(buf, node.members[key], append(keys[:len(keys):len(keys)], key)); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// encodeOrderedValue is a helper function that writes a member value, recursing into nodes.
+func (u *unflattener) encodeOrderedValue(buf *bytes.Buffer, value interface{}, keys []string) error {
+	if node, ok := value.(*orderedNode); ok {
+		return u.encodeOrdered(buf, node, keys)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
@@ -0,0 +1,118 @@
+package goflat_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestUnflattenOrderedInput(t *testing.T) {
+	// Test case 1: Nested member order follows input pair order
+	pairs := goflat.OrderedFlatInput{
+		{Key: "name", Value: "John"},
+		{Key: addressStreetKey, Value: addressStreet},
+		{Key: "age", Value: 30},
+		{Key: addressCityKey, Value: addressCity},
+		{Key: hobbies0Key, Value: hobbies0},
+		{Key: hobbies1Key, Value: hobbies1},
+		{Key: "address.state", Value: "NY"},
+	}
+	options := goflat.DefaultOptions()
+	result, err := goflat.UnflattenOrderedInput(pairs, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	expected := `{"name":"John","address":{"street":"123 Main St","city":"New York","state":"NY"},"age":30,"hobbies":["reading","gaming"]}`
+	if string(result) != expected {
+		t.Errorf("Unexpected output: %s", result)
+	}
+
+	// Test case 2: Reversed input reverses member order
+	pairs = goflat.OrderedFlatInput{
+		{Key: "b.y", Value: 2},
+		{Key: "b.x", Value: 1},
+		{Key: "a", Value: 0},
+	}
+	result, err = goflat.UnflattenOrderedInput(pairs, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if string(result) != `{"b":{"y":2,"x":1},"a":0}` {
+		t.Errorf("Unexpected output: %s", result)
+	}
+
+	// Test case 3: Conflicting keys are reported
+	pairs = goflat.OrderedFlatInput{
+		{Key: "a", Value: 0},
+		{Key: "a.b", Value: 1},
+	}
+	_, err = goflat.UnflattenOrderedInput(pairs, options)
+	if !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+
+	// Test case 4: Numeric top-level keys stay an object like UnflattenJSON, nested ones become arrays
+	pairs = goflat.OrderedFlatInput{
+		{Key: "0", Value: "a"},
+		{Key: "1", Value: "b"},
+		{Key: "2.0", Value: "c"},
+	}
+	result, err = goflat.UnflattenOrderedInput(pairs, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if string(result) != `{"0":"a","1":"b","2":["c"]}` {
+		t.Errorf("Unexpected output: %s", result)
+	}
+	unordered, err := goflat.UnflattenJSON(map[string]interface{}{"0": "a", "1": "b", "2.0": "c"}, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if expected, _ := json.Marshal(unordered); string(result) != string(expected) {
+		t.Errorf("Ordered output %s does not match %s", result, expected)
+	}
+}
+
+func TestFlattenJSONOrdered(t *testing.T) {
+	data := []byte(`{"name":"John","address":{"street":"123 Main St","city":"New York"},"hobbies":["reading","gaming"],"age":30}`)
+
+	// Test case 1: Keys are returned in source order
+	options := goflat.DefaultOptions()
+	options.TrackOrder = true
+	flattened, order, err := goflat.FlattenJSONOrdered(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expectedOrder := []string{"name", addressStreetKey, addressCityKey, hobbies0Key, hobbies1Key, "age"}
+	if !reflect.DeepEqual(order, expectedOrder) {
+		t.Errorf("Key order does not match expected result: %v", order)
+	}
+
+	// Test case 2: Member order survives the round trip
+	out, err := goflat.UnflattenJSONOrdered(flattened, order, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if string(out) != string(data) {
+		t.Errorf("Round trip does not preserve member order: %s", out)
+	}
+
+	// Test case 3: Without TrackOrder no order is recorded
+	_, order, err = goflat.FlattenJSONOrdered(data, goflat.DefaultOptions())
+	if err != nil || order != nil {
+		t.Errorf("Expected no order without TrackOrder, got %v (%v)", order, err)
+	}
+	// Test case 4: A top-level object with numeric keys round trips as an object
+	data = []byte(`{"1":"b","0":"a"}`)
+	flattened, order, err = goflat.FlattenJSONOrdered(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if out, err = goflat.UnflattenJSONOrdered(flattened, order, options); err != nil || string(out) != string(data) {
+		t.Errorf("Round trip does not keep the top-level object: %s (%v)", out, err)
+	}
+
+}
@@ -0,0 +1,352 @@
+package goflat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Notation selects how array indices are written in flattened keys.
+type Notation int
+
+const (
+	NotationDot     Notation = iota // Indices are ordinary segments, e.g. "hobbies.0"
+	NotationBracket                 // Indices are bracketed after their parent, e.g. "hobbies[0]"
+)
+
+// segment is a single parsed key segment.
+type segment struct {
+	key       string // The map key, or the decimal index when bracketed is set
+	bracketed bool   // Whether the segment was written in bracket notation
+}
+
+// PathJoin joins key segments into a flattened key using the same rules as FlattenJSON.
+// Delimiters, escape characters and (with bracket notation) brackets inside a segment are
+// escaped when options.EscapeChar is set. With NotationBracket, a segment of the form "[n]"
+// is written as an array index attached to its parent, and with an IndexDelimiter it is written
+// as an index after that delimiter.
+//
+// Example:
+//
+//	options := DefaultOptions()
+//	options.EscapeChar = '\\'
+//	fmt.Println(PathJoin([]string{"a.b", "c"}, options))
+//
+// Output:
+//
+//	a\.b.c
+func PathJoin(segments []string, options Options) string {
+	var b strings.Builder
+	for i, seg := range segments {
+		if options.ArrayNotation == NotationBracket {
+			if _, ok := parseBracket(seg); ok {
+				b.WriteString(seg)
+				continue
+			}
+		} else if options.IndexDelimiter != "" && i > 0 {
+			if index, ok := parseBracket(seg); ok {
+				b.WriteString(indexDelimiter(options))
+				b.WriteString(index)
+				continue
+			}
+		}
+		if i > 0 || options.JSONPointer {
+			b.WriteString(options.KeyDelimiter)
+		}
+		b.WriteString(escapeSegment(seg, options))
+	}
+	return b.String()
+}
+
+// PathSplit splits a flattened key into its segments, reversing PathJoin.
+// Escaped characters are unescaped, and with NotationBracket array indices are returned as
+// separate segments of the form "[n]", as are indices after an IndexDelimiter.
+//
+// Example:
+//
+//	options := DefaultOptions()
+//	options.ArrayNotation = NotationBracket
+//	fmt.Println(PathSplit("users[0].name", options))
+//
+// Output:
+//
+//	[users [0] name]
+func PathSplit(key string, options Options) []string {
+	segments := splitKey(key, options)
+	result := make([]string, len(segments))
+	for i, seg := range segments {
+		if seg.bracketed {
+			result[i] = "[" + seg.key + "]"
+		} else {
+			result[i] = seg.key
+		}
+	}
+	return result
+}
+
+// Rekey converts flattened keys from the key scheme of from to that of to, honoring the delimiter,
+// escaping, JSON Pointer and array notation settings of each, without unflattening the document.
+// Bracketed indices become indices in the target notation; with NotationDot sources, numeric
+// segments cannot be told apart from map keys and are kept as ordinary segments.
+// Keys that end up identical fail with ErrKeyCollision.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"address.city": "New York", "a/b.c": 1}
+//	rekeyed, err := Rekey(flattened, DefaultOptions(), JSONPointerOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(rekeyed)
+//
+// Output:
+//
+//	map[/a~1b/c:1 /address/city:New York]
+func Rekey(flattened map[string]interface{}, from, to Options) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(flattened))
+	sources := make(map[string]string, len(flattened))
+	for key, value := range flattened {
+		newKey := joinSegments(splitKey(key, from), to)
+		if other, ok := sources[newKey]; ok {
+			return nil, fmt.Errorf("%w: %q and %q both become %q", ErrKeyCollision, other, key, newKey)
+		}
+		sources[newKey] = key
+		result[newKey] = value
+	}
+	return result, nil
+}
+
+// joinSegments is a helper function that joins parsed segments according to options, writing
+// bracketed segments as array indices.
+func joinSegments(segments []segment, options Options) string {
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		parts[i] = seg.key
+		if seg.bracketed && (options.ArrayNotation == NotationBracket || options.IndexDelimiter != "") {
+			parts[i] = "[" + seg.key + "]"
+		}
+	}
+	return PathJoin(parts, options)
+}
+
+// escapeSegment is a helper function that escapes the characters of seg that would otherwise
+// be read as structure. The delimiter is matched as a whole string and the escape character as a
+// rune, so multibyte delimiters and escape characters are handled like single-byte ones.
+func escapeSegment(seg string, options Options) string {
+	if options.JSONPointer {
+		seg = strings.ReplaceAll(seg, "~", "~0")
+		return strings.ReplaceAll(seg, options.KeyDelimiter, "~1")
+	}
+	if options.EscapeChar == 0 {
+		return seg
+	}
+	return string(appendEscaped(nil, seg, options))
+}
+
+// appendEscaped is a helper function that appends seg to b with delimiters, brackets (with
+// NotationBracket) and the escape character escaped by Options.EscapeChar.
+func appendEscaped(b []byte, seg string, options Options) []byte {
+	for i := 0; i < len(seg); {
+		if options.KeyDelimiter != "" && strings.HasPrefix(seg[i:], options.KeyDelimiter) {
+			b = utf8.AppendRune(b, options.EscapeChar)
+			b = append(b, options.KeyDelimiter...)
+			i += len(options.KeyDelimiter)
+			continue
+		}
+		if d := options.IndexDelimiter; d != "" && d != options.KeyDelimiter && strings.HasPrefix(seg[i:], d) {
+			b = utf8.AppendRune(b, options.EscapeChar)
+			b = append(b, d...)
+			i += len(d)
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(seg[i:])
+		if r == options.EscapeChar || (options.ArrayNotation == NotationBracket && (r == '[' || r == ']')) {
+			b = utf8.AppendRune(b, options.EscapeChar)
+		}
+		b = append(b, seg[i:i+size]...)
+		i += size
+	}
+	return b
+}
+
+// splitKey is a helper function that parses a flattened key into segments.
+func splitKey(key string, options Options) []segment {
+	if parser, ok := options.KeyBuilder.(KeyParser); ok {
+		var segments []segment
+		for _, seg := range parser.Split(key) {
+			if index, ok := parseBracket(seg); ok {
+				segments = append(segments, segment{key: index, bracketed: true})
+			} else {
+				segments = append(segments, segment{key: seg})
+			}
+		}
+		return segments
+	}
+	if options.JSONPointer {
+		return splitPointer(key, options)
+	}
+	if options.EscapeChar == 0 && !parsesBrackets(options) && indexDelimiter(options) == options.KeyDelimiter {
+		var segments []segment
+		for _, k := range strings.Split(key, options.KeyDelimiter) {
+			segments = append(segments, segment{key: k})
+		}
+		return segments
+	}
+
+	var segments []segment
+	var b strings.Builder
+	pending := true // Whether b holds a segment that has not been emitted yet
+	for i := 0; i < len(key); {
+		if options.KeyDelimiter != "" && strings.HasPrefix(key[i:], options.KeyDelimiter) {
+			if pending {
+				segments = append(segments, segment{key: b.String()})
+			}
+			b.Reset()
+			pending = true
+			i += len(options.KeyDelimiter)
+			continue
+		}
+		if index, n := parseIndexDelimiter(key[i:], options); n > 0 {
+			if pending && (b.Len() > 0 || len(segments) > 0 || i > 0) {
+				segments = append(segments, segment{key: b.String()})
+			}
+			segments = append(segments, segment{key: index, bracketed: true})
+			b.Reset()
+			pending = false
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(key[i:])
+		if options.EscapeChar != 0 && r == options.EscapeChar && i+size < len(key) {
+			_, next := utf8.DecodeRuneInString(key[i+size:])
+			b.WriteString(key[i+size : i+size+next])
+			pending = true
+			i += size + next
+			continue
+		}
+		if parsesBrackets(options) && r == '[' {
+			if end := strings.IndexByte(key[i:], ']'); end > 0 {
+				if index, ok := parseBracket(key[i : i+end+1]); ok {
+					if pending && (b.Len() > 0 || len(segments) > 0 || i > 0) {
+						segments = append(segments, segment{key: b.String()})
+					}
+					segments = append(segments, segment{key: index, bracketed: true})
+					b.Reset()
+					pending = false
+					i += end + 1
+					continue
+				}
+			}
+		}
+		b.WriteString(key[i : i+size])
+		pending = true
+		i += size
+	}
+	if pending {
+		segments = append(segments, segment{key: b.String()})
+	}
+	return segments
+}
+
+// splitPointer is a helper function that parses a JSON Pointer key: the leading delimiter is dropped
+// and "~1" and "~0" in each segment are unescaped to the delimiter and "~", in that order.
+func splitPointer(key string, options Options) []segment {
+	key = strings.TrimPrefix(key, options.KeyDelimiter)
+	var segments []segment
+	for _, k := range strings.Split(key, options.KeyDelimiter) {
+		k = strings.ReplaceAll(k, "~1", options.KeyDelimiter)
+		segments = append(segments, segment{key: strings.ReplaceAll(k, "~0", "~")})
+	}
+	return segments
+}
+
+// ParseArraySegment parses a single bracket notation segment of the form "name[index]", as used
+// by NotationBracket. Segments without a bracketed index, with an empty or non-numeric index, or
+// with more than one bracket pair report isArray false and return the segment as name.
+//
+// Example:
+//
+//	fmt.Println(ParseArraySegment("hobbies[1]"))
+//
+// Output:
+//
+//	hobbies 1 true
+func ParseArraySegment(segment string) (name string, index int, isArray bool) {
+	open := strings.IndexByte(segment, '[')
+	if open < 0 || strings.ContainsAny(segment[:open], "[]") {
+		return segment, 0, false
+	}
+	digits, ok := parseBracket(segment[open:])
+	if !ok {
+		return segment, 0, false
+	}
+	index, err := strconv.Atoi(digits)
+	if err != nil {
+		return segment, 0, false
+	}
+	return segment[:open], index, true
+}
+
+// parsesBrackets is a helper function that reports whether keys are parsed with bracketed indices.
+func parsesBrackets(options Options) bool {
+	return options.ArrayNotation == NotationBracket || options.MixedNotation
+}
+
+// indexDelimiter is a helper function that returns the delimiter written before array indices
+// in dot notation.
+func indexDelimiter(options Options) string {
+	if options.IndexDelimiter != "" {
+		return options.IndexDelimiter
+	}
+	return options.KeyDelimiter
+}
+
+// parseIndexDelimiter is a helper function that parses an array index written after a distinct
+// IndexDelimiter at the start of s. It returns the digits and the number of bytes consumed, or 0
+// when s does not start with such an index. The digits must end s or be followed by a delimiter.
+func parseIndexDelimiter(s string, options Options) (string, int) {
+	d := options.IndexDelimiter
+	if d == "" || d == options.KeyDelimiter || options.ArrayNotation != NotationDot || !strings.HasPrefix(s, d) {
+		return "", 0
+	}
+	rest := s[len(d):]
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return "", 0
+	}
+	if end < len(rest) && !strings.HasPrefix(rest[end:], options.KeyDelimiter) && !strings.HasPrefix(rest[end:], d) {
+		return "", 0
+	}
+	return rest[:end], len(d) + end
+}
+
+// parseBracket is a helper function that parses a bracketed array index such as "[3]" and
+// returns its digits. Zero-padded indices such as "[007]" are accepted.
+func parseBracket(seg string) (string, bool) {
+	if len(seg) < 3 || seg[0] != '[' || seg[len(seg)-1] != ']' {
+		return "", false
+	}
+	digits := seg[1 : len(seg)-1]
+	if !isDigits(digits) {
+		return "", false
+	}
+	return digits, true
+}
+
+// isDigits is a helper function that reports whether s is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
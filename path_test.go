@@ -0,0 +1,331 @@
+package goflat_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestPathJoinSplit(t *testing.T) {
+	// Test case 1: Segments containing the delimiter and the escape character
+	options := goflat.DefaultOptions()
+	options.EscapeChar = '\\'
+	segments := []string{"a.b", `c\d`, "e"}
+	key := goflat.PathJoin(segments, options)
+	if key != `a\.b.c\\d.e` {
+		t.Errorf("Unexpected joined key: %s", key)
+	}
+	if result := goflat.PathSplit(key, options); !reflect.DeepEqual(result, segments) {
+		t.Errorf("Split segments do not match: %v", result)
+	}
+
+	// Test case 2: Bracket notation with indices and literal brackets in a map key
+	options.ArrayNotation = goflat.NotationBracket
+	segments = []string{"users", "[0]", "tags[x]", "[12]"}
+	key = goflat.PathJoin(segments, options)
+	if key != `users[0].tags\[x\][12]` {
+		t.Errorf("Unexpected joined key: %s", key)
+	}
+	if result := goflat.PathSplit(key, options); !reflect.DeepEqual(result, segments) {
+		t.Errorf("Split segments do not match: %v", result)
+	}
+
+	// Test case 3: Without escaping, splitting is a plain split on the delimiter
+	options = goflat.DefaultOptions()
+	if result := goflat.PathSplit("a.b.0", options); !reflect.DeepEqual(result, []string{"a", "b", "0"}) {
+		t.Errorf("Split segments do not match: %v", result)
+	}
+}
+
+func TestFlattenEscaping(t *testing.T) {
+	// Test case 1: Map keys containing the delimiter are escaped during flatten
+	data := []byte(`{"a.b": {"c": 1}, "list": [{"x.y": true}]}`)
+	options := goflat.DefaultOptions()
+	options.EscapeChar = '\\'
+	expected := map[string]interface{}{
+		`a\.b.c`:      float64(1),
+		`list.0.x\.y`: true,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+	assertRoundTrip(t, data, options)
+
+	// Test case 2: Bracket notation round trip
+	options.ArrayNotation = goflat.NotationBracket
+	expected = map[string]interface{}{
+		`a\.b.c`:       float64(1),
+		`list[0].x\.y`: true,
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+	assertRoundTrip(t, []byte(`{"m": [[1, 2], [{"k[0]": "v"}]]}`), options)
+}
+
+func TestMultibyteDelimiter(t *testing.T) {
+	// Test case 1: Flattening with a multibyte delimiter
+	data := []byte(`{"a": {"b": [1, {"c": "d"}]}, "x•y": {"z": true}}`)
+	options := goflat.DefaultOptions()
+	options.KeyDelimiter = "•"
+	options.EscapeChar = '\\'
+	expected := map[string]interface{}{
+		"a•b•0":   float64(1),
+		"a•b•1•c": "d",
+		`x\•y•z`:  true,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Round trips with dot and bracket notation and a multibyte escape character
+	assertRoundTrip(t, data, options)
+	options.ArrayNotation = goflat.NotationBracket
+	assertRoundTrip(t, data, options)
+	options.EscapeChar = '¦'
+	assertRoundTrip(t, []byte(`{"k¦•": ["•", {"••": "¦"}], "é": [[0]]}`), options)
+
+	// Test case 3: PathJoin and PathSplit agree on segments containing the delimiter
+	segments := []string{"a•b", "[3]", "•", "c¦"}
+	key := goflat.PathJoin(segments, options)
+	if key != "a¦•b[3]•¦••c¦¦" {
+		t.Errorf("Unexpected joined key: %s", key)
+	}
+	if split := goflat.PathSplit(key, options); !reflect.DeepEqual(split, segments) {
+		t.Errorf("Split segments do not match: %v", split)
+	}
+}
+
+func TestJSONPointerFlatten(t *testing.T) {
+	// Test case 1: Keys are JSON Pointers with ~0 and ~1 escaping
+	data := []byte(`{"address": {"city": "New York"}, "a/b": {"m~n": 1}, "hobbies": ["reading", {"x/y": true}]}`)
+	options := goflat.JSONPointerOptions()
+	expected := map[string]interface{}{
+		"/address/city":   addressCity,
+		"/a~1b/m~0n":      float64(1),
+		"/hobbies/0":      hobbies0,
+		"/hobbies/1/x~1y": true,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: PathJoin builds the same pointers
+	if key := goflat.PathJoin([]string{"a/b", "m~n"}, options); key != "/a~1b/m~0n" {
+		t.Errorf("Unexpected joined key: %s", key)
+	}
+}
+
+func TestJSONPointerUnflatten(t *testing.T) {
+	// Test case 1: Pointers with escaped "/" and "~" are reconstructed
+	flattened := map[string]interface{}{
+		"/address/city":   addressCity,
+		"/a~1b/m~0n":      float64(1),
+		"/~01":            "tilde one",
+		"/hobbies/0":      hobbies0,
+		"/hobbies/1/x~1y": true,
+	}
+	options := goflat.JSONPointerOptions()
+	expected := map[string]interface{}{
+		"address": map[string]interface{}{"city": addressCity},
+		"a/b":     map[string]interface{}{"m~n": float64(1)},
+		"~1":      "tilde one",
+		"hobbies": []interface{}{hobbies0, map[string]interface{}{"x/y": true}},
+	}
+	result, err := goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 2: Flattening and unflattening with pointers round-trips
+	data := []byte(`{"a/b": {"~": [1, {"c~/d": null}]}, "": {"empty": true}}`)
+	assertRoundTrip(t, data, options)
+
+	// Test case 3: PathSplit reverses PathJoin
+	if split := goflat.PathSplit("/a~1b/m~0n", options); !reflect.DeepEqual(split, []string{"a/b", "m~n"}) {
+		t.Errorf("Split segments do not match: %v", split)
+	}
+}
+
+func TestMongoOptions(t *testing.T) {
+	// Test case 1: Keys use dot notation with numeric array segments
+	data := []byte(`{"name": "John", "items": [{"qty": 2}, {"qty": 5, "tags": ["a"]}], "price$": 9}`)
+	options := goflat.MongoOptions()
+	expected := map[string]interface{}{
+		"name":           "John",
+		"items.0.qty":    float64(2),
+		"items.1.qty":    float64(5),
+		"items.1.tags.0": "a",
+		"price$":         float64(9),
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Field names with a ".", a leading "$" or no characters are rejected
+	for _, data := range []string{
+		`{"address": {"zip.code": "10001"}}`,
+		`{"items": [{"$inc": 1}]}`,
+		`{"": 1}`,
+	} {
+		if _, err := goflat.FlattenJSON([]byte(data), options); !errors.Is(err, goflat.ErrInvalidFieldName) {
+			t.Errorf("Expected ErrInvalidFieldName for %s, got %v", data, err)
+		}
+	}
+
+	// Test case 3: Keys are checked after they are rewritten
+	options.EmptyKeyReplacement = "_"
+	if _, err := goflat.FlattenJSON([]byte(`{"": 1}`), options); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	// Test case 1: Converting "."-keys to "/"-keys, including keys containing "/"
+	from := goflat.DefaultOptions()
+	from.EscapeChar = '\\'
+	to := goflat.DefaultOptions()
+	to.KeyDelimiter = "/"
+	to.EscapeChar = '\\'
+	flattened := map[string]interface{}{
+		addressCityKey: addressCity,
+		"a/b.c":        1,
+		`x\.y.z`:       2,
+	}
+	expected := map[string]interface{}{
+		"address/city": addressCity,
+		`a\/b/c`:       1,
+		"x.y/z":        2,
+	}
+	result, err := goflat.Rekey(flattened, from, to)
+	if err != nil {
+		t.Errorf("Error rekeying: %+v", err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Rekeyed map does not match expected result: %v", result)
+	}
+
+	// Test case 2: Converting bracket notation to JSON Pointers
+	from = goflat.DefaultOptions()
+	from.ArrayNotation = goflat.NotationBracket
+	flattened = map[string]interface{}{
+		"hobbies[0]":   hobbies0,
+		"users[1].a/b": true,
+	}
+	expected = map[string]interface{}{
+		"/hobbies/0":    hobbies0,
+		"/users/1/a~1b": true,
+	}
+	result, err = goflat.Rekey(flattened, from, goflat.JSONPointerOptions())
+	if err != nil {
+		t.Errorf("Error rekeying: %+v", err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Rekeyed map does not match expected result: %v", result)
+	}
+
+	// Test case 3: Keys that collide in the target scheme are rejected
+	to = goflat.DefaultOptions()
+	to.KeyDelimiter = "/"
+	flattened = map[string]interface{}{
+		"a/b": 1,
+		"a.b": 2,
+	}
+	_, err = goflat.Rekey(flattened, goflat.DefaultOptions(), to)
+	if !errors.Is(err, goflat.ErrKeyCollision) {
+		t.Errorf("Expected ErrKeyCollision, got %v", err)
+	}
+}
+
+func TestParseArraySegment(t *testing.T) {
+	// Test case 1: A bracketed index
+	name, index, isArray := goflat.ParseArraySegment("a[0]")
+	if name != "a" || index != 0 || !isArray {
+		t.Errorf("Unexpected result for a[0]: %q, %d, %v", name, index, isArray)
+	}
+
+	// Test case 2: A plain segment
+	name, index, isArray = goflat.ParseArraySegment("a")
+	if name != "a" || index != 0 || isArray {
+		t.Errorf("Unexpected result for a: %q, %d, %v", name, index, isArray)
+	}
+
+	// Test case 3: An empty index
+	name, _, isArray = goflat.ParseArraySegment("a[]")
+	if name != "a[]" || isArray {
+		t.Errorf("Unexpected result for a[]: %q, %v", name, isArray)
+	}
+
+	// Test case 4: A non-numeric index
+	name, _, isArray = goflat.ParseArraySegment("a[b]")
+	if name != "a[b]" || isArray {
+		t.Errorf("Unexpected result for a[b]: %q, %v", name, isArray)
+	}
+
+	// Test case 5: Nested and unbalanced brackets
+	for _, segment := range []string{"a[0][1]", "a]0[", "a[0"} {
+		if _, _, isArray = goflat.ParseArraySegment(segment); isArray {
+			t.Errorf("Expected %q not to be an array segment", segment)
+		}
+	}
+}
+
+func TestIndexDelimiter(t *testing.T) {
+	// Test case 1: Indices follow "#" while map keys are separated by "."
+	data := []byte(`{"a": {"b": [1, {"c": 2}]}, "m": [[true]], "hobbies": ["reading", "gaming"]}`)
+	options := goflat.DefaultOptions()
+	options.IndexDelimiter = "#"
+	expected := map[string]interface{}{
+		"a.b#0":     float64(1),
+		"a.b#1.c":   float64(2),
+		"m#0#0":     true,
+		"hobbies#0": hobbies0,
+		"hobbies#1": hobbies1,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: The keys unflatten back into the original document
+	assertRoundTrip(t, data, options)
+
+	// Test case 3: Map keys containing "#" survive with escaping
+	options.EscapeChar = '\\'
+	data = []byte(`{"issue#42": {"labels": ["bug"]}, "x#y": 1}`)
+	assertRoundTrip(t, data, options)
+	if split := goflat.PathSplit(`issue\#42.labels#0`, options); !reflect.DeepEqual(split, []string{"issue#42", "labels", "[0]"}) {
+		t.Errorf("Split segments do not match: %v", split)
+	}
+	if key := goflat.PathJoin([]string{"issue#42", "labels", "[0]"}, options); key != `issue\#42.labels#0` {
+		t.Errorf("Unexpected joined key: %s", key)
+	}
+}
@@ -0,0 +1,111 @@
+package goflat
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+)
+
+// flattenState holds the scratch space of a FlattenJSONPooled call, kept in statePool so that its
+// storage is reused across calls: the map the top-level object is decoded into and the buffer keys
+// are built in.
+type flattenState struct {
+	decoded map[string]interface{}
+	keyBuf  []byte
+}
+
+// statePool is the pool of flattenState values used by FlattenJSONPooled.
+var statePool = sync.Pool{
+	New: func() interface{} {
+		return &flattenState{
+			decoded: make(map[string]interface{}),
+			keyBuf:  make([]byte, 0, 64),
+		}
+	},
+}
+
+// FlattenJSONPooled flattens a JSON object like FlattenJSON, reusing pooled scratch space between
+// calls: the map the top-level object is decoded into and the byte buffer every key is built in
+// before it is copied into its final string. Keys that FlattenJSON assembles in several steps,
+// such as escaped segments and indices of 100 and over, then cost a single allocation. This
+// helps when many small documents are flattened, for example in a busy service. The returned map
+// belongs to the caller; the pooled state is reset before it is reused.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "address": {"city": "New York"}}`)
+//	flattened, err := FlattenJSONPooled(data, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(flattened["address.city"])
+//
+// Output:
+//
+//	New York
+func FlattenJSONPooled(data []byte, options Options) (map[string]interface{}, error) {
+	state := statePool.Get().(*flattenState)
+	defer func() {
+		clear(state.decoded)
+		state.keyBuf = state.keyBuf[:0]
+		statePool.Put(state)
+	}()
+
+	decoded := state.decoded
+	if options.PreserveNumberText {
+		var err error
+		if decoded, err = decodeNumberText(data); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+
+	f := newFlattener(options)
+	f.keyBuf = &state.keyBuf
+	f.run(decoded)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.flattened, nil
+}
+
+// bufferedChild is a helper function that builds the key of the map member k under key in the
+// pooled key buffer, like childKey.
+func (f *flattener) bufferedChild(key string, k string) string {
+	b := append((*f.keyBuf)[:0], key...)
+	b = append(b, f.options.KeyDelimiter...)
+	seg := f.normalizeKey(k)
+	if f.options.EscapeChar != 0 && !f.options.JSONPointer {
+		b = appendEscaped(b, seg, f.options)
+	} else {
+		b = append(b, escapeSegment(seg, f.options)...)
+	}
+	*f.keyBuf = b
+	return string(b)
+}
+
+// bufferedIndex is a helper function that builds the key of the array element i under key in the
+// pooled key buffer, like joinIndex without IndexBucketSize.
+func (f *flattener) bufferedIndex(key string, i int) string {
+	bracket := f.options.ArrayNotation == NotationBracket
+	b := append((*f.keyBuf)[:0], key...)
+	if bracket {
+		b = append(b, '[')
+	} else {
+		b = append(b, indexDelimiter(f.options)...)
+	}
+	start := len(b)
+	b = strconv.AppendInt(b, int64(i), 10)
+	digits := len(b) - start
+	if f.options.MaxIndexDigits > 0 && digits > f.options.MaxIndexDigits ||
+		f.options.PadIndexWidth > 0 && digits != f.options.PadIndexWidth {
+		b = append(b[:start], f.formatIndex(key, i)...)
+	}
+	if bracket {
+		b = append(b, ']')
+	}
+	*f.keyBuf = b
+	return string(b)
+}
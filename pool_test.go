@@ -0,0 +1,117 @@
+package goflat_test
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+// pooledDocument is a small document of the kind a busy service flattens many times.
+var pooledDocument = []byte(`{"name": "John", "age": 30, "address": {"street": "123 Main St", "city": "New York"}, "hobbies": ["reading", "gaming"]}`)
+
+func TestFlattenJSONPooled(t *testing.T) {
+	// Test case 1: Pooled and standard results match across reuses of the pooled state
+	bracket := goflat.DefaultOptions()
+	bracket.ArrayNotation = goflat.NotationBracket
+	bracket.EscapeChar = '\\'
+	padded := goflat.DefaultOptions()
+	padded.PadIndexWidth = 3
+	padded.IndexDelimiter = "#"
+	for _, options := range []goflat.Options{goflat.DefaultOptions(), bracket, padded, goflat.JSONPointerOptions()} {
+		data := []byte(`{"a.b": {"c[0]": "x", "d/e~": [1, 2, 3]}, "list": ["#", {"k": 1}], "big": [` + strings.Repeat(`0,`, 120) + `1]}`)
+		for _, data := range [][]byte{pooledDocument, data} {
+			expected, err := goflat.FlattenJSON(data, options)
+			if err != nil {
+				t.Fatalf(errorFlatteningJSON, err)
+			}
+			for i := 0; i < 3; i++ {
+				result, err := goflat.FlattenJSONPooled(data, options)
+				if err != nil {
+					t.Errorf(errorFlatteningJSON, err)
+				}
+				if !reflect.DeepEqual(result, expected) {
+					t.Errorf("Pooled result %v does not match %v", result, expected)
+				}
+			}
+		}
+	}
+
+	// Test case 2: A returned map is not touched by later calls
+	first, err := goflat.FlattenJSONPooled(pooledDocument, goflat.DefaultOptions())
+	if err != nil {
+		t.Fatalf(errorFlatteningJSON, err)
+	}
+	if _, err := goflat.FlattenJSONPooled([]byte(`{"other": {"key": 1}}`), goflat.DefaultOptions()); err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if first[addressCityKey] != addressCity || len(first) != 6 {
+		t.Errorf("Returned map changed after a later call: %v", first)
+	}
+
+	// Test case 3: Concurrent calls each see their own document
+	expected, _ := goflat.FlattenJSON(pooledDocument, goflat.DefaultOptions())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				result, err := goflat.FlattenJSONPooled(pooledDocument, goflat.DefaultOptions())
+				if err != nil {
+					t.Errorf(errorFlatteningJSON, err)
+				}
+				if !reflect.DeepEqual(result, expected) {
+					t.Errorf(errorFlattenedJSONMismatch)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Test case 4: Invalid JSON is reported
+	if _, err := goflat.FlattenJSONPooled([]byte(`{`), goflat.DefaultOptions()); err == nil {
+		t.Errorf("Expected an error for invalid JSON")
+	}
+}
+
+func BenchmarkFlattenJSONPooled(b *testing.B) {
+	// Escaped keys and indices of 100 and over take several steps to build.
+	var buf bytes.Buffer
+	buf.WriteString(`{"user.name": "John", "rows": [`)
+	for i := 0; i < 200; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`{"id": ` + strconv.Itoa(i) + `, "geo.lat": 1.5}`)
+	}
+	buf.WriteString(`]}`)
+	data := buf.Bytes()
+	options := goflat.DefaultOptions()
+	options.EscapeChar = '\\'
+
+	b.Run("standard", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := goflat.FlattenJSON(data, options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := goflat.FlattenJSONPooled(data, options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
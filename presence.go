@@ -0,0 +1,37 @@
+package goflat
+
+// FlattenJSONWithPresence flattens a JSON object like FlattenJSON and also returns the set of keys
+// present in the document: every leaf, null ones included, and every object or array on the way
+// to one, so "address" is present for {"address": {}} even though it flattens to nothing. A
+// consumer applying the result as a patch can tell a key set to null, which is present, from a
+// key left unchanged, which is not. Keys rewritten after the walk by TrimPrefix, PrefixFromPath,
+// SlugifyKeys or RenameRegex, and keys added by Derive, are listed as walked.
+//
+// Example:
+//
+//	data := []byte(`{"name": null, "address": {}}`)
+//	_, present, err := FlattenJSONWithPresence(data, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	_, hasName := present["name"]
+//	_, hasAge := present["age"]
+//	fmt.Println(len(present), hasName, hasAge)
+//
+// Output:
+//
+//	2 true false
+func FlattenJSONWithPresence(data []byte, options Options) (map[string]interface{}, map[string]struct{}, error) {
+	decoded, err := jsonDecoder(options)(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := newFlattener(options)
+	f.presence = make(map[string]struct{})
+	f.run(decoded)
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	return f.flattened, f.presence, nil
+}
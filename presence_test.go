@@ -0,0 +1,42 @@
+package goflat_test
+
+import (
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFlattenJSONWithPresence(t *testing.T) {
+	data := []byte(`{"name": "John", "nick": null, "address": {"city": "New York", "zip": null}, "tags": [], "meta": {}}`)
+
+	// Test case 1: Null leaves and empty containers are present, absent keys are not
+	flattened, present, err := goflat.FlattenJSONWithPresence(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Fatalf(errorFlatteningJSON, err)
+	}
+	expected := map[string]struct{}{
+		"name":         {},
+		"nick":         {},
+		"address":      {},
+		addressCityKey: {},
+		"address.zip":  {},
+		"tags":         {},
+		"meta":         {},
+	}
+	if !reflect.DeepEqual(present, expected) {
+		t.Errorf("Presence set does not match expected result: %v", present)
+	}
+	if _, ok := present["age"]; ok {
+		t.Errorf("Expected the absent key age not to be present")
+	}
+
+	// Test case 2: The flattened map is the same as FlattenJSON's
+	plain, err := goflat.FlattenJSON(data, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(flattened, plain) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
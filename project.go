@@ -0,0 +1,44 @@
+package goflat
+
+import "strings"
+
+// Project flattens a JSON object but keeps only the flattened keys listed in paths, which are
+// matched exactly, not as globs. Subtrees that cannot contain any of the paths are skipped
+// without being walked, so projecting a few keys out of a large document is cheap. Paths missing
+// from the document are absent from the result; a path naming an object or array keeps it whole.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "age": 30, "address": {"city": "New York", "state": "NY"}}`)
+//	projected, err := Project(data, []string{"name", "address.city"}, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(projected)
+//
+// Output:
+//
+//	map[address.city:New York name:John]
+func Project(data []byte, paths []string, options Options) (map[string]interface{}, error) {
+	decoded, err := jsonDecoder(options)(data)
+	if err != nil {
+		return nil, err
+	}
+	f := newFlattener(options)
+	f.targets = make(map[string]bool, len(paths))
+	f.ancestors = make(map[string]bool)
+	for _, path := range paths {
+		f.targets[path] = true
+		for i := range path {
+			if strings.HasPrefix(path[i:], options.KeyDelimiter) || path[i] == '[' {
+				f.ancestors[path[:i]] = true
+			}
+		}
+	}
+	f.run(decoded)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.flattened, nil
+}
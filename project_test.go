@@ -0,0 +1,58 @@
+package goflat_test
+
+import (
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestProject(t *testing.T) {
+	data := []byte(`{
+		"name": "John",
+		"age": 30,
+		"address": {"street": "123 Main St", "city": "New York", "geo": {"lat": 40.7, "lng": -74}},
+		"hobbies": ["reading", "gaming"],
+		"orders": [{"id": 1, "items": [{"sku": "a"}, {"sku": "b"}]}, {"id": 2}]
+	}`)
+
+	// Test case 1: Three paths are projected out of a larger document
+	paths := []string{addressCityKey, hobbies1Key, "orders.0.items.1.sku"}
+	expected := map[string]interface{}{
+		addressCityKey:         addressCity,
+		hobbies1Key:            hobbies1,
+		"orders.0.items.1.sku": "b",
+	}
+	result, err := goflat.Project(data, paths, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Missing paths are absent and container paths are kept whole
+	paths = []string{"address.zip", "address.geo", "missing.key"}
+	expected = map[string]interface{}{
+		"address.geo": map[string]interface{}{"lat": 40.7, "lng": float64(-74)},
+	}
+	result, err = goflat.Project(data, paths, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 3: Bracket notation paths
+	options := goflat.DefaultOptions()
+	options.ArrayNotation = goflat.NotationBracket
+	expected = map[string]interface{}{"orders[1].id": float64(2)}
+	result, err = goflat.Project(data, []string{"orders[1].id"}, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
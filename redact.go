@@ -0,0 +1,54 @@
+package goflat
+
+import "strings"
+
+// FlattenRedacted flattens a JSON object and replaces the values of keys matching any of the
+// sensitive glob patterns ("*" matches any run of characters, delimiters included, "?" a single
+// one) with options.RedactionMarker, keeping the keys themselves. A leaf is also redacted when the
+// key of one of its containers matches, so "*.credentials" hides every leaf below it.
+//
+// Example:
+//
+//	data := []byte(`{"user": {"name": "John", "password": "hunter2"}}`)
+//	redacted, err := FlattenRedacted(data, []string{"*.password"}, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(redacted)
+//
+// Output:
+//
+//	map[user.name:John user.password:[REDACTED]]
+func FlattenRedacted(data []byte, sensitive []string, options Options) (map[string]interface{}, error) {
+	flattened, err := FlattenJSON(data, options)
+	if err != nil {
+		return nil, err
+	}
+	marker := options.RedactionMarker
+	if marker == "" {
+		marker = DefaultRedactionMarker
+	}
+	for key := range flattened {
+		if isSensitive(key, sensitive, options) {
+			flattened[key] = marker
+		}
+	}
+	return flattened, nil
+}
+
+// isSensitive is a helper function that reports whether key, or the key of one of its containers,
+// matches one of the sensitive patterns.
+func isSensitive(key string, sensitive []string, options Options) bool {
+	if matchAny(sensitive, key) {
+		return true
+	}
+	for i := range key {
+		if (options.KeyDelimiter != "" && strings.HasPrefix(key[i:], options.KeyDelimiter)) || key[i] == '[' {
+			if i > 0 && matchAny(sensitive, key[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package goflat_test
+
+import (
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestFlattenRedacted(t *testing.T) {
+	data := []byte(`{
+		"name": "John",
+		"user": {"password": "hunter2", "ssn": "123-45-6789", "email": "john@example.com"},
+		"accounts": [{"id": 1, "password": "secret"}],
+		"vault": {"credentials": {"token": "abc", "keys": ["k1", "k2"]}}
+	}`)
+
+	// Test case 1: Password and ssn fields are redacted, other fields are intact
+	sensitive := []string{"*.password", "*.ssn"}
+	expected := map[string]interface{}{
+		"name":                     "John",
+		"user.password":            goflat.DefaultRedactionMarker,
+		"user.ssn":                 goflat.DefaultRedactionMarker,
+		"user.email":               "john@example.com",
+		"accounts.0.id":            float64(1),
+		"accounts.0.password":      goflat.DefaultRedactionMarker,
+		"vault.credentials.token":  "abc",
+		"vault.credentials.keys.0": "k1",
+		"vault.credentials.keys.1": "k2",
+	}
+	result, err := goflat.FlattenRedacted(data, sensitive, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: A matching container redacts every leaf below it, with a custom marker
+	options := goflat.DefaultOptions()
+	options.RedactionMarker = "***"
+	result, err = goflat.FlattenRedacted(data, []string{"*.credentials"}, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	for _, key := range []string{"vault.credentials.token", "vault.credentials.keys.0", "vault.credentials.keys.1"} {
+		if result[key] != "***" {
+			t.Errorf("Expected %s to be redacted, got %v", key, result[key])
+		}
+	}
+	if result["user.password"] != "hunter2" {
+		t.Errorf("Unexpected redaction of user.password")
+	}
+}
@@ -0,0 +1,48 @@
+package goflat
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// RegexRename is a single rule of Options.RenameRegex.
+type RegexRename struct {
+	Pattern *regexp.Regexp // The expression matched against flattened keys
+	Replace string         // The replacement for every match, expanded as by ReplaceAllString
+}
+
+// renameRegex is a helper function that rewrites every flattened key per Options.RenameRegex,
+// resolving or rejecting keys that collide.
+func (f *flattener) renameRegex() {
+	renamed := make(map[string]string, len(f.flattened))
+	sources := make(map[string]string, len(f.flattened))
+	for _, key := range sortedKeys(f.flattened) {
+		newKey := applyRenames(key, f.options.RenameRegex)
+		if other, ok := sources[newKey]; ok {
+			if !f.options.RenameRegexLastWins {
+				f.fail(fmt.Errorf("%w: %q and %q both become %q", ErrKeyCollision, other, key, newKey))
+				return
+			}
+			f.lose(newKey, f.flattened[other])
+			delete(f.flattened, other)
+			delete(f.positions, other)
+			delete(renamed, other)
+		}
+		sources[newKey] = key
+		renamed[key] = newKey
+	}
+	f.rekey(func(key string) string {
+		if newKey, ok := renamed[key]; ok {
+			return newKey
+		}
+		return applyRenames(key, f.options.RenameRegex)
+	})
+}
+
+// applyRenames is a helper function that applies rules to key in order.
+func applyRenames(key string, rules []RegexRename) string {
+	for _, rule := range rules {
+		key = rule.Pattern.ReplaceAllString(key, rule.Replace)
+	}
+	return key
+}
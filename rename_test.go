@@ -0,0 +1,61 @@
+package goflat_test
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestRenameRegex(t *testing.T) {
+	data := []byte(`{"meta_owner": "John", "user": {"meta_source": "web", "group_id": 7}, "tags": ["a"]}`)
+
+	// Test case 1: A prefix is stripped from every segment and a segment is reformatted
+	options := goflat.DefaultOptions()
+	options.RenameRegex = []goflat.RegexRename{
+		{Pattern: regexp.MustCompile(`(^|\.)meta_`), Replace: "$1"},
+		{Pattern: regexp.MustCompile(`(\w+)_id$`), Replace: "${1}ID"},
+	}
+	expected := map[string]interface{}{
+		"owner":        "John",
+		"user.source":  "web",
+		"user.groupID": float64(7),
+		"tags.0":       "a",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Keys that collide after rewriting are rejected
+	data = []byte(`{"meta_name": "a", "name": "b"}`)
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrKeyCollision) {
+		t.Errorf("Expected ErrKeyCollision, got %v", err)
+	}
+
+	// Test case 3: With RenameRegexLastWins the key sorting last is kept
+	options.RenameRegexLastWins = true
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"name": "b"}) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 4: Streaming walks see the rewritten keys
+	var keys []string
+	err = goflat.WalkLeaves(map[string]interface{}{"meta_x": 1}, options, func(key string, value interface{}) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(keys, []string{"x"}) {
+		t.Errorf("Unexpected walked keys: %v (%v)", keys, err)
+	}
+}
@@ -0,0 +1,101 @@
+package goflat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// slugifyKeys is a helper function that rewrites every flattened key per Options.SlugifyKeys,
+// numbering or rejecting keys that collide.
+func (f *flattener) slugifyKeys() {
+	f.rewriteSegments(func(seg string) string {
+		return slugify(seg, f.options.SlugReplacement)
+	}, f.options.SlugSuffixCollisions)
+}
+
+// rewriteSegments is a helper function that rewrites every map key segment of every flattened key
+// with rewrite. Keys that collide are numbered "key_2", "key_3" and so on in sorted order of their
+// source keys when suffix is set, and fail with ErrKeyCollision otherwise.
+func (f *flattener) rewriteSegments(rewrite func(seg string) string, suffix bool) {
+	renamed := make(map[string]string, len(f.flattened))
+	sources := make(map[string]string, len(f.flattened))
+	for _, key := range sortedKeys(f.flattened) {
+		newKey := rewriteKey(key, f.options, rewrite)
+		if other, ok := sources[newKey]; ok {
+			if !suffix {
+				f.fail(fmt.Errorf("%w: %q and %q both become %q", ErrKeyCollision, other, key, newKey))
+				return
+			}
+			base := newKey
+			for n := 2; ; n++ {
+				newKey = base + "_" + strconv.Itoa(n)
+				if _, ok := sources[newKey]; !ok {
+					break
+				}
+			}
+		}
+		sources[newKey] = key
+		renamed[key] = newKey
+	}
+	f.rekey(func(key string) string {
+		if newKey, ok := renamed[key]; ok {
+			return newKey
+		}
+		return rewriteKey(key, f.options, rewrite)
+	})
+}
+
+// rewriteKey is a helper function that rewrites each map key segment of key with rewrite and
+// builds the key again with options.KeyBuilder, or with the delimiters and escaping of options
+// when there is none. key is returned unchanged when rewrite changes no segment.
+func rewriteKey(key string, options Options, rewrite func(seg string) string) string {
+	segments := splitKey(key, options)
+	changed := false
+	for i, seg := range segments {
+		if seg.bracketed {
+			continue
+		}
+		if newSeg := rewrite(seg.key); newSeg != seg.key {
+			segments[i].key = newSeg
+			changed = true
+		}
+	}
+	if !changed {
+		return key
+	}
+	if options.KeyBuilder == nil {
+		return joinSegments(segments, options)
+	}
+	newKey := ""
+	for _, seg := range segments {
+		if index, ok := parseIndex(seg.key); ok && seg.bracketed {
+			newKey = options.KeyBuilder.Index(newKey, index)
+		} else {
+			newKey = options.KeyBuilder.Child(newKey, seg.key)
+		}
+	}
+	return newKey
+}
+
+// slugify is a helper function that keeps the ASCII letters, digits, "_" and "-" of s and writes
+// replacement, or '_' when it is 0, for every run of other characters.
+func slugify(s string, replacement rune) string {
+	if replacement == 0 {
+		replacement = '_'
+	}
+	var b strings.Builder
+	replaced := false // Whether the last character written is a replacement
+	for _, r := range s {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			b.WriteRune(r)
+			replaced = false
+			continue
+		}
+		if !replaced {
+			b.WriteRune(replacement)
+			replaced = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package goflat_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestSlugifyKeys(t *testing.T) {
+	// Test case 1: Spaces and special characters are replaced within each segment
+	data := []byte(`{"user name": "John", "e-mail": {"home (main)": "j@example.com"}, "tags": ["a"]}`)
+	options := goflat.DefaultOptions()
+	options.SlugifyKeys = true
+	expected := map[string]interface{}{
+		"user_name":         "John",
+		"e-mail.home_main_": "j@example.com",
+		"tags.0":            "a",
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: A custom replacement character
+	options.SlugReplacement = '-'
+	data = []byte(`{"café menu": {"price $": 3}}`)
+	expected = map[string]interface{}{"caf-menu.price-": float64(3)}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 3: Keys that collide after slugifying fail
+	options = goflat.DefaultOptions()
+	options.SlugifyKeys = true
+	data = []byte(`{"a b": 1, "a-b": 2, "a_b": 3, "a!b": 4}`)
+	_, err = goflat.FlattenJSON(data, options)
+	if !errors.Is(err, goflat.ErrKeyCollision) {
+		t.Errorf("Expected ErrKeyCollision, got %v", err)
+	}
+
+	// Test case 4: Colliding keys are numbered in sorted order of their source keys
+	options.SlugSuffixCollisions = true
+	expected = map[string]interface{}{
+		"a_b":   float64(1),
+		"a_b_2": float64(4),
+		"a_b_3": float64(3),
+		"a-b":   float64(2),
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 5: Keys are rebuilt with a custom KeyBuilder
+	options = goflat.DefaultOptions()
+	options.SlugifyKeys = true
+	options.KeyBuilder = arrowKeys{}
+	data = []byte(`{"user list": [{"e mail": "j@example.com"}], "name": "John"}`)
+	expected = map[string]interface{}{
+		"user_list(0)->e_mail": "j@example.com",
+		"name":                 "John",
+	}
+	result, err = goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+}
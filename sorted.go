@@ -0,0 +1,218 @@
+package goflat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UnflattenSorted unflattens parallel slices of flattened keys and their values like
+// UnflattenJSON, building the tree in one pass over keys sorted in byte order. Consecutive sorted
+// keys share their leading segments, so the containers along the previous key are kept on a stack
+// and only the part of each key after the bytes it shares with the previous one is split and
+// looked up. Every container is complete once the keys move past it, so arrays are rebuilt as
+// they are left instead of in a second pass over the tree. Keys that are not sorted are sorted
+// first. This fast path covers plain delimited keys; options that change how keys split, such as
+// NotationBracket, EscapeChar or PadIndexWidth, split every key in full, and options that
+// UnflattenJSON handles per key, such as TagArrays, EmitArrayLength, TypedValues,
+// LenientUnflatten and ArraysRequireBrackets, fall back to that handling. keys and values of
+// different lengths fail with ErrLengthMismatch.
+//
+// Example:
+//
+//	keys := []string{"address.city", "address.state", "name"}
+//	values := []interface{}{"New York", "NY", "John"}
+//	unflattened, err := UnflattenSorted(keys, values, DefaultOptions())
+//	if err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	fmt.Println(unflattened)
+//
+// Output:
+//
+//	map[address:map[city:New York state:NY] name:John]
+func UnflattenSorted(keysSorted []string, values []interface{}, options Options) (interface{}, error) {
+	if len(keysSorted) != len(values) {
+		return nil, fmt.Errorf("%w: %d keys and %d values", ErrLengthMismatch, len(keysSorted), len(values))
+	}
+	order := make([]int, len(keysSorted))
+	for i := range order {
+		order[i] = i
+	}
+	if !sort.StringsAreSorted(keysSorted) {
+		sort.SliceStable(order, func(i, j int) bool { return keysSorted[order[i]] < keysSorted[order[j]] })
+	}
+
+	if rawSplit(options) {
+		return unflattenSortedRaw(keysSorted, values, order, options)
+	}
+
+	u := newUnflattener(options)
+	perKey := options.TagArrays || options.EmitArrayLength || options.TypedValues || options.LenientUnflatten ||
+		options.ArraysRequireBrackets
+	stack := []map[string]interface{}{u.result} // The containers along path, the root first
+	var path []string                           // The segments of the containers on the stack
+	for _, i := range order {
+		key, value := keysSorted[i], values[i]
+		if perKey {
+			if err := u.add(key, value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		keys := splitKeys(key, options)
+		if !u.indexed {
+			for _, k := range keys {
+				if _, ok := parseIndex(k); ok {
+					u.indexed = true
+					break
+				}
+			}
+		}
+		shared := 0
+		for shared < len(path) && shared < len(keys)-1 && path[shared] == keys[shared] {
+			shared++
+		}
+		stack, path = stack[:shared+1], path[:shared]
+		for _, k := range keys[shared : len(keys)-1] {
+			parent := stack[len(stack)-1]
+			child, ok := parent[k]
+			if !ok {
+				child = make(map[string]interface{})
+				parent[k] = child
+			}
+			next, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrKeyConflict, key)
+			}
+			stack, path = append(stack, next), append(path, k)
+		}
+		parent, last := stack[len(stack)-1], keys[len(keys)-1]
+		if _, ok := parent[last].(map[string]interface{}); ok {
+			return nil, fmt.Errorf("%w: %q", ErrKeyConflict, key)
+		}
+		parent[last] = value
+	}
+	if !u.needsPromotion() {
+		return u.result, nil
+	}
+	return u.promoteMembers(u.result), nil
+}
+
+// rawSplit is a helper function that reports whether keys written with options split on every
+// KeyDelimiter and nothing else, with each segment used as it is.
+func rawSplit(options Options) bool {
+	return options.KeyBuilder == nil && !options.JSONPointer && options.KeyDelimiter != "" &&
+		options.EscapeChar == 0 && !parsesBrackets(options) && indexDelimiter(options) == options.KeyDelimiter &&
+		options.EmptyKeyReplacement == "" && options.PadIndexWidth <= 0 && options.IndexBucketSize <= 0 &&
+		!options.TagArrays && !options.EmitArrayLength && !options.TypedValues && !options.LenientUnflatten &&
+		!options.ArraysRequireBrackets
+}
+
+// sortedFrame is a container open on the stack of unflattenSortedRaw.
+type sortedFrame struct {
+	m       map[string]interface{}
+	name    string // The segment naming the container in its parent
+	end     int    // The offset of the delimiter after the segment in the previous key
+	indices bool   // Whether every member is an array index
+	max     int    // The largest member index, or -1
+}
+
+// member is a helper function that records k as a new member of the container.
+func (fr *sortedFrame) member(k string) {
+	if i, ok := indexSegment(k); ok {
+		fr.max = max(fr.max, i)
+	} else {
+		fr.indices = false
+	}
+}
+
+// unflattenSortedRaw is a helper function that unflattens keys visited in order per UnflattenSorted
+// when every key splits per rawSplit. Only the bytes of a key after those it shares with the
+// previous key are scanned for delimiters, and segments are substrings of the key.
+func unflattenSortedRaw(keysSorted []string, values []interface{}, order []int, options Options) (interface{}, error) {
+	delim := options.KeyDelimiter
+	stack := []sortedFrame{{m: make(map[string]interface{})}} // The root first, never an array
+	leave := func(n int) {
+		for len(stack) > n {
+			fr := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !fr.indices || fr.max != len(fr.m)-1 || len(fr.m) < options.MinArrayKeys {
+				continue
+			}
+			arr := make([]interface{}, len(fr.m))
+			for k, val := range fr.m {
+				i, _ := indexSegment(k)
+				arr[i] = val
+			}
+			stack[len(stack)-1].m[fr.name] = arr
+		}
+	}
+
+	prev := ""
+	for _, i := range order {
+		key, value := keysSorted[i], values[i]
+		common := 0
+		for common < len(prev) && common < len(key) && prev[common] == key[common] {
+			common++
+		}
+		shared := 1
+		for shared < len(stack) && stack[shared].end+len(delim) <= common {
+			shared++
+		}
+		leave(shared)
+		start := 0
+		if top := stack[len(stack)-1]; len(stack) > 1 {
+			start = top.end + len(delim)
+		}
+		for {
+			j := strings.Index(key[start:], delim)
+			if j < 0 {
+				break
+			}
+			k, parent := key[start:start+j], &stack[len(stack)-1]
+			child, ok := parent.m[k]
+			if !ok {
+				child = make(map[string]interface{})
+				parent.m[k] = child
+				parent.member(k)
+			}
+			next, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrKeyConflict, key)
+			}
+			fr := sortedFrame{m: next, name: k, end: start + j, indices: true, max: -1}
+			for member := range next {
+				fr.member(member)
+			}
+			stack = append(stack, fr)
+			start += j + len(delim)
+		}
+		last, parent := key[start:], &stack[len(stack)-1]
+		if existing, ok := parent.m[last]; ok {
+			if _, ok := existing.(map[string]interface{}); ok {
+				return nil, fmt.Errorf("%w: %q", ErrKeyConflict, key)
+			}
+		} else {
+			parent.member(last)
+		}
+		parent.m[last] = value
+		prev = key
+	}
+	leave(1)
+	return stack[0].m, nil
+}
+
+// indexSegment is a helper function that parses a canonical array index like parseIndex without
+// allocating.
+func indexSegment(k string) (int, bool) {
+	if !isDigits(k) || len(k) > 18 || len(k) > 1 && k[0] == '0' {
+		return 0, false
+	}
+	i := 0
+	for j := 0; j < len(k); j++ {
+		i = i*10 + int(k[j]-'0')
+	}
+	return i, true
+}
@@ -0,0 +1,180 @@
+package goflat_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestUnflattenSorted(t *testing.T) {
+	// Test case 1: Sorted keys build the same tree as UnflattenJSON, arrays included
+	flattened := map[string]interface{}{
+		"name":           "John",
+		addressCityKey:   addressCity,
+		"address.state":  "NY",
+		hobbies0Key:      hobbies0,
+		hobbies1Key:      hobbies1,
+		"users.0.name":   "Ann",
+		"users.1.name":   "Bob",
+		"users.1.tags.0": "admin",
+	}
+	keys := make([]string, 0, len(flattened))
+	for key := range flattened {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = flattened[key]
+	}
+	expected, err := goflat.UnflattenJSON(flattened, goflat.DefaultOptions())
+	if err != nil {
+		t.Fatalf(errorUnflatteningJSON, err)
+	}
+	result, err := goflat.UnflattenSorted(keys, values, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 2: Unsorted keys are sorted first without reordering the caller's slices
+	reversed := make([]string, len(keys))
+	reversedValues := make([]interface{}, len(values))
+	for i := range keys {
+		reversed[i] = keys[len(keys)-1-i]
+		reversedValues[i] = values[len(values)-1-i]
+	}
+	first := reversed[0]
+	result, err = goflat.UnflattenSorted(reversed, reversedValues, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+	if reversed[0] != first {
+		t.Errorf("Expected the input keys to be left in place, got %v", reversed)
+	}
+
+	// Test case 3: A key needing a leaf and a nested value at once conflicts
+	_, err = goflat.UnflattenSorted([]string{"a", "a.b"}, []interface{}{1, 2}, goflat.DefaultOptions())
+	if !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+	_, err = goflat.UnflattenSorted([]string{"a.b", "a"}, []interface{}{1, 2}, goflat.DefaultOptions())
+	if !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+
+	// Test case 4: Keys and values of different lengths are rejected
+	_, err = goflat.UnflattenSorted([]string{"a"}, nil, goflat.DefaultOptions())
+	if !errors.Is(err, goflat.ErrLengthMismatch) {
+		t.Errorf("Expected ErrLengthMismatch, got %v", err)
+	}
+
+	// Test case 5: Keys sharing a prefix that do not sort next to each other
+	result, err = goflat.UnflattenSorted([]string{"a-c", "a.b", "a.d"}, []interface{}{1, 2, 3}, goflat.DefaultOptions())
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	expected = map[string]interface{}{"a-c": 1, "a": map[string]interface{}{"b": 2, "d": 3}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 6: Options handled per key fall back to UnflattenJSON's handling
+	options := goflat.DefaultOptions()
+	options.ArrayNotation = goflat.NotationBracket
+	options.ArraysRequireBrackets = true
+	result, err = goflat.UnflattenSorted([]string{"codes.0", "tags[0]"}, []interface{}{"x", "y"}, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	expected = map[string]interface{}{
+		"codes": map[string]interface{}{"0": "x"},
+		"tags":  []interface{}{"y"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 7: Random documents build the same tree as UnflattenJSON with other delimiters too
+	rng := rand.New(rand.NewSource(1))
+	multi := goflat.DefaultOptions()
+	multi.KeyDelimiter = "__"
+	multi.MinArrayKeys = 2
+	for _, options := range []goflat.Options{goflat.DefaultOptions(), multi} {
+		for i := 0; i < 200; i++ {
+			data, err := json.Marshal(map[string]interface{}{"root": randomNode(rng, 6, true)})
+			if err != nil {
+				t.Fatalf("Error encoding test input: %+v", err)
+			}
+			flattened, err := goflat.FlattenJSON(data, options)
+			if err != nil {
+				t.Fatalf(errorFlatteningJSON, err)
+			}
+			keys, values := sortedPairs(flattened)
+			expected, err := goflat.UnflattenJSON(flattened, options)
+			if err != nil {
+				t.Fatalf(errorUnflatteningJSON, err)
+			}
+			result, err := goflat.UnflattenSorted(keys, values, options)
+			if err != nil {
+				t.Errorf(errorUnflatteningJSON, err)
+			}
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("Sorted result %v does not match %v", result, expected)
+			}
+		}
+	}
+}
+
+// sortedPairs is a helper function that returns the keys of flattened in byte order and their values.
+func sortedPairs(flattened map[string]interface{}) ([]string, []interface{}) {
+	keys := make([]string, 0, len(flattened))
+	for key := range flattened {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = flattened[key]
+	}
+	return keys, values
+}
+
+func BenchmarkUnflattenSorted(b *testing.B) {
+	flattened := make(map[string]interface{})
+	for i := 0; i < 200; i++ {
+		for _, field := range []string{"name", "address.city", "address.state", "address.geo.lat", "address.geo.lng"} {
+			flattened[fmt.Sprintf("records.%d.%s", i, field)] = i
+		}
+	}
+	keys, values := sortedPairs(flattened)
+	options := goflat.DefaultOptions()
+
+	b.Run("sorted", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := goflat.UnflattenSorted(keys, values, options); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := goflat.UnflattenJSON(flattened, options); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
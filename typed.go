@@ -0,0 +1,150 @@
+package goflat
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+)
+
+// Kind names the JSON type of a flattened value, as used by TypedValue envelopes, FlattenToRows
+// and FlattenValidate schemas.
+type Kind string
+
+// Kinds of flattened values.
+const (
+	TypeNull    Kind = "null"    // A nil value
+	TypeBoolean Kind = "boolean" // A bool
+	TypeInteger Kind = "integer" // A Go integer, or a number without a fractional part
+	TypeNumber  Kind = "number"  // Any other number
+	TypeString  Kind = "string"  // A string
+	TypeArray   Kind = "array"   // A slice or array kept whole, e.g. with Options.PreserveArrays
+	TypeObject  Kind = "object"  // A map kept whole, e.g. with Options.OpaquePaths
+	TypeOther   Kind = "other"   // Any other Go value
+)
+
+// TypedValue is a flattened value together with a tag naming its JSON type, as stored when
+// Options.TypedValues is set. It marshals to {"type":"integer","value":30}.
+type TypedValue struct {
+	Type  Kind
+	Value interface{}
+}
+
+// MarshalJSON encodes v as an object with "type" and "value" members.
+func (v TypedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  Kind        `json:"type"`
+		Value interface{} `json:"value"`
+	}{v.Type, v.Value})
+}
+
+// newTypedValue is a helper function that wraps value in a TypedValue tagged with its type.
+func newTypedValue(value interface{}) TypedValue {
+	return TypedValue{Type: valueType(value), Value: value}
+}
+
+// valueType is a helper function that returns the type tag of a value.
+func valueType(value interface{}) Kind {
+	switch v := value.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case string:
+		return TypeString
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return TypeInteger
+		}
+		return TypeNumber
+	case float32:
+		return floatType(float64(v))
+	case float64:
+		return floatType(v)
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return TypeInteger
+	case reflect.Float32, reflect.Float64:
+		return TypeNumber
+	case reflect.Slice, reflect.Array:
+		return TypeArray
+	case reflect.Map:
+		return TypeObject
+	case reflect.String:
+		return TypeString
+	case reflect.Bool:
+		return TypeBoolean
+	}
+	return TypeOther
+}
+
+// kindTags holds the key prefixes written by Options.KindPrefixKeys.
+var kindTags = map[Kind]string{
+	TypeNull:    "null",
+	TypeBoolean: "bool",
+	TypeInteger: "num",
+	TypeNumber:  "num",
+	TypeString:  "str",
+	TypeArray:   "arr",
+	TypeObject:  "obj",
+	TypeOther:   "any",
+}
+
+// prefixKinds is a helper function that prepends the kind tag of every value to its key, per
+// Options.KindPrefixKeys. Values wrapped by Options.TypedValues keep the kind of their envelope.
+func (f *flattener) prefixKinds() {
+	f.rekey(func(key string) string {
+		value := f.flattened[key]
+		kind := valueType(value)
+		if typed, ok := value.(TypedValue); ok {
+			kind = typed.Type
+		}
+		return kindTags[kind] + ":" + key
+	})
+}
+
+// floatType is a helper function that tags a float as an integer when it has no fractional part
+// and fits an int64.
+func floatType(f float64) Kind {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return TypeInteger
+	}
+	return TypeNumber
+}
+
+// untypedValue is a helper function that unwraps a TypedValue, or its decoded JSON form, and
+// converts its value to the Go type its tag names. Other values are returned unchanged.
+func untypedValue(value interface{}) interface{} {
+	var typ Kind
+	switch v := value.(type) {
+	case TypedValue:
+		typ, value = v.Type, v.Value
+	case *TypedValue:
+		if v == nil {
+			return value
+		}
+		typ, value = v.Type, v.Value
+	case map[string]interface{}:
+		t, ok := v["type"].(string)
+		inner, found := v["value"]
+		if !ok || !found || len(v) != 2 {
+			return value
+		}
+		typ, value = Kind(t), inner
+	default:
+		return value
+	}
+	if typ != TypeInteger {
+		return value
+	}
+	switch v := value.(type) {
+	case float64:
+		return int64(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+	}
+	return value
+}
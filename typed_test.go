@@ -0,0 +1,134 @@
+package goflat_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestTypedValues(t *testing.T) {
+	data := []byte(`{"age": 30, "score": 9.5, "active": true, "name": "John", "nick": null, "address": {"city": "New York"}}`)
+	options := goflat.DefaultOptions()
+	options.TypedValues = true
+
+	// Test case 1: Every value is wrapped in a tagged envelope
+	expected := map[string]interface{}{
+		"age":          goflat.TypedValue{Type: goflat.TypeInteger, Value: float64(30)},
+		"score":        goflat.TypedValue{Type: goflat.TypeNumber, Value: 9.5},
+		"active":       goflat.TypedValue{Type: goflat.TypeBoolean, Value: true},
+		"name":         goflat.TypedValue{Type: goflat.TypeString, Value: "John"},
+		"nick":         goflat.TypedValue{Type: goflat.TypeNull, Value: nil},
+		addressCityKey: goflat.TypedValue{Type: goflat.TypeString, Value: addressCity},
+	}
+	flattened, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(flattened, expected) {
+		t.Errorf(errorFlattenedJSONMismatch)
+	}
+
+	// Test case 2: Envelopes marshal to type and value members
+	encoded, err := json.Marshal(flattened["age"])
+	if err != nil || string(encoded) != `{"type":"integer","value":30}` {
+		t.Errorf("Unexpected envelope encoding: %s (%v)", encoded, err)
+	}
+
+	// Test case 3: Unflattening restores each type, with integers as int64
+	want := map[string]interface{}{
+		"age":     int64(30),
+		"score":   9.5,
+		"active":  true,
+		"name":    "John",
+		"nick":    nil,
+		"address": map[string]interface{}{"city": addressCity},
+	}
+	result, err := goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 4: Envelopes read back from stored JSON are unwrapped too
+	stored, err := goflat.FlattenJSONToBytes(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(stored, &decoded); err != nil {
+		t.Fatalf("Error decoding stored JSON: %v", err)
+	}
+	result, err = goflat.UnflattenJSON(decoded, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+}
+
+func TestKindPrefixKeys(t *testing.T) {
+	// Test case 1: Every scalar kind gets its tag
+	data := []byte(`{"name": "John", "age": 30, "score": 9.5, "active": true, "nick": null, "address": {"city": "New York"}, "hobbies": ["reading"]}`)
+	options := goflat.DefaultOptions()
+	options.KindPrefixKeys = true
+	expected := map[string]interface{}{
+		"str:name":              "John",
+		"num:age":               float64(30),
+		"num:score":             9.5,
+		"bool:active":           true,
+		"null:nick":             nil,
+		"str:" + addressCityKey: addressCity,
+		"str:" + hobbies0Key:    hobbies0,
+	}
+	result, err := goflat.FlattenJSON(data, options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Prefixed map does not match expected result: %v", result)
+	}
+
+	// Test case 2: Containers stored whole are tagged arr and obj
+	options.PreserveArrays = true
+	options.OpaquePaths = []string{"address"}
+	expected = map[string]interface{}{
+		"arr:hobbies": []interface{}{hobbies0},
+		"obj:address": map[string]interface{}{"city": addressCity},
+	}
+	result, err = goflat.FlattenJSON([]byte(`{"address": {"city": "New York"}, "hobbies": ["reading"]}`), options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Prefixed map does not match expected result: %v", result)
+	}
+
+	// Test case 3: The tags are added after other key rewrites and with TypedValues envelopes
+	options = goflat.DefaultOptions()
+	options.KindPrefixKeys = true
+	options.TrimPrefix = "data"
+	options.TypedValues = true
+	result, err = goflat.FlattenJSON([]byte(`{"data": {"age": 30}}`), options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if _, ok := result["num:age"]; !ok || len(result) != 1 {
+		t.Errorf("Prefixed map does not match expected result: %v", result)
+	}
+
+	// Test case 4: Positions follow the prefixed keys
+	options = goflat.DefaultOptions()
+	options.KindPrefixKeys = true
+	_, positions, err := goflat.FlattenJSONWithPositions([]byte(`{"name": "John", "age": 30}`), options)
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(positions, map[string]int{"str:name": 9, "num:age": 24}) {
+		t.Errorf("Positions do not match expected result: %v", positions)
+	}
+}
@@ -0,0 +1,45 @@
+package goflat
+
+// Unflattener rebuilds a nested document from flattened keys fed to it one at a time,
+// so that a large flat input never needs to be held as a single map.
+type Unflattener struct {
+	u *unflattener
+}
+
+// NewUnflattener creates an Unflattener that interprets keys according to options.
+//
+// Example:
+//
+//	u := NewUnflattener(DefaultOptions())
+//	if err := u.Add("address.city", "New York"); err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	if err := u.Add("hobbies.0", "reading"); err != nil {
+//		fmt.Println("Error:", err)
+//		return
+//	}
+//	result, _ := u.Result()
+//	fmt.Println(result)
+//
+// Output:
+//
+//	map[address:map[city:New York] hobbies:[reading]]
+func NewUnflattener(options Options) *Unflattener {
+	return &Unflattener{u: newUnflattener(options)}
+}
+
+// Add sets a single flattened key. It returns an error wrapping ErrKeyConflict when the key
+// clashes with one added before.
+func (u *Unflattener) Add(key string, value interface{}) error {
+	return u.u.add(key, value)
+}
+
+// Result returns the nested document built from the keys added so far, with arrays rebuilt
+// as in UnflattenJSON. More keys can still be added afterwards.
+func (u *Unflattener) Result() (interface{}, error) {
+	if !u.u.needsPromotion() {
+		return DeepCopy(u.u.result), nil
+	}
+	return u.u.promoteMembers(DeepCopy(u.u.result)), nil
+}
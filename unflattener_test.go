@@ -0,0 +1,66 @@
+package goflat_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestUnflattener(t *testing.T) {
+	// Test case 1: Feeding keys one at a time matches a batch UnflattenJSON
+	flattened := map[string]interface{}{
+		"name":           "John",
+		"age":            30,
+		addressStreetKey: addressStreet,
+		addressCityKey:   addressCity,
+		hobbies0Key:      hobbies0,
+		hobbies1Key:      hobbies1,
+		"matrix.0.0":     1,
+		"matrix.1.0":     2,
+	}
+	keys := make([]string, 0, len(flattened))
+	for key := range flattened {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	options := goflat.DefaultOptions()
+	u := goflat.NewUnflattener(options)
+	for _, key := range keys {
+		if err := u.Add(key, flattened[key]); err != nil {
+			t.Errorf(errorUnflatteningJSON, err)
+		}
+	}
+	result, err := u.Result()
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	expected, err := goflat.UnflattenJSON(flattened, options)
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 2: Keys can still be added after Result
+	if err := u.Add("hobbies.2", "chess"); err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	result, err = u.Result()
+	if err != nil {
+		t.Errorf(errorUnflatteningJSON, err)
+	}
+	hobbies := result.(map[string]interface{})["hobbies"]
+	if !reflect.DeepEqual(hobbies, []interface{}{hobbies0, hobbies1, "chess"}) {
+		t.Errorf(errorUnflattenedJSONMismatch)
+	}
+
+	// Test case 3: Conflicts are reported on Add
+	if err := u.Add("name.first", "John"); !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package goflat
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidateKeys checks that every key of flattened matches at least one of the allowed glob
+// patterns, where "*" matches any run of characters and "?" a single character.
+// All non-conforming keys are reported together in a single error wrapping ErrKeyNotAllowed.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"name": "John", "address.city": "New York", "debug": true}
+//	err := ValidateKeys(flattened, []string{"name", "address.*"})
+//	fmt.Println(err)
+//
+// Output:
+//
+//	goflat: key not allowed: "debug"
+func ValidateKeys(flattened map[string]interface{}, allowed []string) error {
+	var stray []string
+	for key := range flattened {
+		if !matchAny(allowed, key) {
+			stray = append(stray, strconv.Quote(key))
+		}
+	}
+	if len(stray) == 0 {
+		return nil
+	}
+	sort.Strings(stray)
+	return fmt.Errorf("%w: %s", ErrKeyNotAllowed, strings.Join(stray, ", "))
+}
+
+// CanUnflatten checks flattened for the structural conflicts UnflattenJSON would fail on, keys
+// holding a leaf at a path that other keys nest under, without building the result. All
+// conflicts are reported together in a single error wrapping ErrKeyConflict, each as the leaf key
+// and the first key, in sorted order, nesting under it.
+//
+// Example:
+//
+//	flattened := map[string]interface{}{"address": "none", "address.city": "New York", "name": "John"}
+//	err := CanUnflatten(flattened, DefaultOptions())
+//	fmt.Println(err)
+//
+// Output:
+//
+//	goflat: key conflicts with an existing value: "address" and "address.city"
+func CanUnflatten(flattened map[string]interface{}, options Options) error {
+	leaves := make(map[string]string, len(flattened))
+	nested := make(map[string]string)
+	for _, key := range sortedKeys(flattened) {
+		keys := splitKeys(key, options)
+		leaves[pathKey(keys)] = key
+		for i := 1; i < len(keys); i++ {
+			if _, ok := nested[pathKey(keys[:i])]; !ok {
+				nested[pathKey(keys[:i])] = key
+			}
+		}
+	}
+
+	var conflicts []string
+	for path, leaf := range leaves {
+		if other, ok := nested[path]; ok {
+			conflicts = append(conflicts, strconv.Quote(leaf)+" and "+strconv.Quote(other))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	return fmt.Errorf("%w: %s", ErrKeyConflict, strings.Join(conflicts, ", "))
+}
+
+// FlattenValidate flattens a JSON object and checks every leaf against the kind schema lists for
+// its key, reporting all mismatches instead of stopping at the first. Integers satisfy
+// TypeNumber, and string leaves that parse as the expected number, integer or boolean are
+// coerced to it in the result. Keys missing from schema are accepted, or reported with
+// ErrKeyNotAllowed when options.FlagUnknownKeys is set; schema keys absent from the document are
+// not reported. Errors are in sorted key order and wrap ErrKindMismatch or ErrKeyNotAllowed; a
+// document that cannot be flattened returns its error alone.
+//
+// Example:
+//
+//	data := []byte(`{"name": "John", "age": "30", "active": "yes"}`)
+//	schema := map[string]Kind{"name": TypeString, "age": TypeInteger, "active": TypeBoolean}
+//	flattened, errs := FlattenValidate(data, schema, DefaultOptions())
+//	fmt.Println(flattened["age"], errs)
+//
+// Output:
+//
+//	30 [goflat: value kind does not match schema: "active" is string, want boolean]
+func FlattenValidate(data []byte, schema map[string]Kind, options Options) (map[string]interface{}, []error) {
+	flattened, err := FlattenJSON(data, options)
+	if err != nil {
+		return nil, []error{err}
+	}
+	var errs []error
+	for _, key := range sortedKeys(flattened) {
+		want, ok := schema[key]
+		if !ok {
+			if options.FlagUnknownKeys {
+				errs = append(errs, fmt.Errorf("%w: %q", ErrKeyNotAllowed, key))
+			}
+			continue
+		}
+		value, ok := coerceKind(flattened[key], want)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %q is %s, want %s", ErrKindMismatch, key, valueType(flattened[key]), want))
+			continue
+		}
+		flattened[key] = value
+	}
+	return flattened, errs
+}
+
+// coerceKind is a helper function that returns value as the kind want, converting strings that
+// parse as the wanted number, integer or boolean, and reports false when value cannot be.
+func coerceKind(value interface{}, want Kind) (interface{}, bool) {
+	have := valueType(value)
+	if have == want || (have == TypeInteger && want == TypeNumber) {
+		return value, true
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	switch want {
+	case TypeNumber:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	case TypeInteger:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+	case TypeBoolean:
+		if s == "true" || s == "false" {
+			return s == "true", true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,120 @@
+package goflat_test
+
+import (
+	"errors"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestValidateKeys(t *testing.T) {
+	allowed := []string{"name", "age", "address.*", "hobbies.?"}
+
+	// Test case 1: A fully-conforming map
+	flattened := map[string]interface{}{
+		"name":           "John",
+		"age":            30,
+		addressStreetKey: addressStreet,
+		addressCityKey:   addressCity,
+		hobbies0Key:      hobbies0,
+	}
+	if err := goflat.ValidateKeys(flattened, allowed); err != nil {
+		t.Errorf("Unexpected validation error: %v", err)
+	}
+
+	// Test case 2: Two stray keys are reported together
+	flattened["debug"] = true
+	flattened["hobbies.10"] = "chess"
+	err := goflat.ValidateKeys(flattened, allowed)
+	if !errors.Is(err, goflat.ErrKeyNotAllowed) {
+		t.Errorf("Expected ErrKeyNotAllowed, got %v", err)
+	}
+	expected := `goflat: key not allowed: "debug", "hobbies.10"`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestCanUnflatten(t *testing.T) {
+	// Test case 1: Data without conflicts passes
+	flattened := map[string]interface{}{
+		"name":         "John",
+		addressCityKey: addressCity,
+		hobbies0Key:    hobbies0,
+	}
+	if err := goflat.CanUnflatten(flattened, goflat.DefaultOptions()); err != nil {
+		t.Errorf("Unexpected validation error: %v", err)
+	}
+
+	// Test case 2: Every conflict is reported at once
+	flattened["address"] = "none"
+	flattened["hobbies.0.name"] = "chess"
+	flattened["name.first"] = "John"
+	err := goflat.CanUnflatten(flattened, goflat.DefaultOptions())
+	if !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+	expected := `goflat: key conflicts with an existing value: "address" and "address.city", "hobbies.0" and "hobbies.0.name", "name" and "name.first"`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+
+	// Test case 3: UnflattenJSON fails on the same data
+	if _, err := goflat.UnflattenJSON(flattened, goflat.DefaultOptions()); !errors.Is(err, goflat.ErrKeyConflict) {
+		t.Errorf("Expected UnflattenJSON to fail with ErrKeyConflict, got %v", err)
+	}
+}
+
+func TestFlattenValidate(t *testing.T) {
+	data := []byte(`{
+		"name": "John",
+		"age": "thirty",
+		"score": 9,
+		"active": "true",
+		"address": {"city": 10001, "zip": "10001"},
+		"debug": true
+	}`)
+	schema := map[string]goflat.Kind{
+		"name":         goflat.TypeString,
+		"age":          goflat.TypeInteger,
+		"score":        goflat.TypeNumber,
+		"active":       goflat.TypeBoolean,
+		addressCityKey: goflat.TypeString,
+		"address.zip":  goflat.TypeInteger,
+		"missing":      goflat.TypeString,
+	}
+
+	// Test case 1: Every violation is reported and conforming strings are coerced
+	flattened, errs := goflat.FlattenValidate(data, schema, goflat.DefaultOptions())
+	expected := []string{
+		`goflat: value kind does not match schema: "address.city" is integer, want string`,
+		`goflat: value kind does not match schema: "age" is string, want integer`,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	for i, err := range errs {
+		if !errors.Is(err, goflat.ErrKindMismatch) || err.Error() != expected[i] {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if flattened["active"] != true || flattened["address.zip"] != int64(10001) || flattened["score"] != float64(9) {
+		t.Errorf("Unexpected coerced values: %v", flattened)
+	}
+	if flattened["age"] != "thirty" {
+		t.Errorf("Mismatched values must be kept as they are: %v", flattened["age"])
+	}
+
+	// Test case 2: Unknown keys are reported with FlagUnknownKeys
+	options := goflat.DefaultOptions()
+	options.FlagUnknownKeys = true
+	_, errs = goflat.FlattenValidate(data, schema, options)
+	if len(errs) != 3 || !errors.Is(errs[2], goflat.ErrKeyNotAllowed) || errs[2].Error() != `goflat: key not allowed: "debug"` {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	// Test case 3: Invalid JSON is reported alone
+	if _, errs = goflat.FlattenValidate([]byte(`{`), schema, options); len(errs) != 1 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+}
@@ -0,0 +1,113 @@
+package goflat
+
+import "strings"
+
+// WalkLeaves flattens data like FlattenMap but calls fn for each entry instead of building the
+// output map, so memory stays proportional to the depth of data rather than its size.
+// The walk stops at the first error returned by fn, which WalkLeaves returns.
+//
+// Options that need every key up front (SortKeys, TrimPrefix, PrefixFromPath, Derive,
+// SlugifyKeys, ASCIIOnlyKeys, RenameRegex and KindPrefixKeys) are honored by flattening into a
+// map first, so such walks are not streamed.
+//
+// Example:
+//
+//	data := map[string]interface{}{"b": 2, "a": map[string]interface{}{"c": 1}}
+//	options := DefaultOptions()
+//	options.SortKeys = true
+//	err := WalkLeaves(data, options, func(key string, value interface{}) error {
+//		fmt.Println(key, value)
+//		return nil
+//	})
+//
+// Output:
+//
+//	a.c 1
+//	b 2
+func WalkLeaves(data map[string]interface{}, options Options, fn func(key string, value interface{}) error) error {
+	if !needsAllKeys(options) {
+		f := newFlattener(options)
+		f.sink = fn
+		f.run(data)
+		return f.err
+	}
+
+	flattened, err := flattenMap(data, options)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	if options.SortKeys {
+		keys = keyOrder(flattened, options)
+	} else {
+		for key := range flattened {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		if err := fn(key, flattened[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// needsAllKeys is a helper function that reports whether options can only be applied once every
+// key is known, which rules out streaming.
+func needsAllKeys(options Options) bool {
+	return options.SortKeys || options.TrimPrefix != "" || options.PrefixFromPath != "" ||
+		options.Derive != nil || options.SlugifyKeys || options.ASCIIOnlyKeys || len(options.RenameRegex) > 0 ||
+		options.KindPrefixKeys
+}
+
+// NaturalKeySort reports whether a sorts before b in natural order, for use as Options.KeySort.
+// Runs of ASCII digits are compared by numeric value, so "item2" sorts before "item10", and
+// everything else is compared byte by byte. Keys that only differ in leading zeros fall back to
+// byte order.
+//
+// Example:
+//
+//	keys := []string{"item10", "item2", "item1"}
+//	sort.Slice(keys, func(i, j int) bool { return NaturalKeySort(keys[i], keys[j]) })
+//	fmt.Println(keys)
+//
+// Output:
+//
+//	[item1 item2 item10]
+func NaturalKeySort(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			startA, startB := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			numA := strings.TrimLeft(a[startA:i], "0")
+			numB := strings.TrimLeft(b[startB:j], "0")
+			if len(numA) != len(numB) {
+				return len(numA) < len(numB)
+			}
+			if numA != numB {
+				return numA < numB
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return a[i] < b[j]
+		}
+		i++
+		j++
+	}
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+	return a < b
+}
+
+// isDigit is a helper function that reports whether c is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,144 @@
+package goflat_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	goflat "github.com/brian-s-side-project/go-flat"
+)
+
+func TestWalkLeaves(t *testing.T) {
+	data := map[string]interface{}{
+		"name": "John",
+		"address": map[string]interface{}{
+			"street": addressStreet,
+			"city":   addressCity,
+		},
+		"hobbies": []interface{}{hobbies0, hobbies1},
+	}
+	options := goflat.DefaultOptions()
+
+	// Test case 1: Every leaf is visited once
+	visited := make(map[string]interface{})
+	err := goflat.WalkLeaves(data, options, func(key string, value interface{}) error {
+		visited[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	if !reflect.DeepEqual(visited, goflat.FlattenMap(data, options)) {
+		t.Errorf(errorFlattenedMapMismatch)
+	}
+
+	// Test case 2: Keys are visited in sorted order with SortKeys
+	options.SortKeys = true
+	var keys []string
+	err = goflat.WalkLeaves(data, options, func(key string, value interface{}) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		t.Errorf(errorFlatteningJSON, err)
+	}
+	expected := []string{addressCityKey, addressStreetKey, hobbies0Key, hobbies1Key, "name"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected key order: %v", keys)
+	}
+
+	// Test case 3: An error from the callback stops the walk
+	options.SortKeys = false
+	stop := errors.New("stop")
+	calls := 0
+	err = goflat.WalkLeaves(data, options, func(key string, value interface{}) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected the walk to stop after one call, got %d calls and %v", calls, err)
+	}
+
+	// Test case 4: fn is not called again when one leaf emits several entries
+	options = goflat.DefaultOptions()
+	options.EmitNumericAggregates = true
+	options.TagArrays = true
+	options.EmitArrayLength = true
+	calls = 0
+	err = goflat.WalkLeaves(map[string]interface{}{"scores": []interface{}{1.0, 2.0}}, options, func(key string, value interface{}) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected the walk to stop after one call, got %d calls and %v", calls, err)
+	}
+}
+
+func TestKeySort(t *testing.T) {
+	data := map[string]interface{}{
+		"item10": 10,
+		"item2":  2,
+		"item1":  1,
+		"list":   []interface{}{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"},
+	}
+	options := goflat.DefaultOptions()
+	options.SortKeys = true
+	collect := func(options goflat.Options) []string {
+		var keys []string
+		err := goflat.WalkLeaves(data, options, func(key string, value interface{}) error {
+			keys = append(keys, key)
+			return nil
+		})
+		if err != nil {
+			t.Errorf(errorFlatteningJSON, err)
+		}
+		return keys
+	}
+
+	// Test case 1: Byte order puts "item10" before "item2"
+	keys := collect(options)
+	if !reflect.DeepEqual(keys[:3], []string{"item1", "item10", "item2"}) || keys[4] != "list.1" || keys[5] != "list.10" {
+		t.Errorf("Unexpected byte order: %v", keys)
+	}
+
+	// Test case 2: Natural order compares numeric runs by value
+	options.KeySort = goflat.NaturalKeySort
+	keys = collect(options)
+	if !reflect.DeepEqual(keys[:3], []string{"item1", "item2", "item10"}) || keys[13] != "list.10" {
+		t.Errorf("Unexpected natural order: %v", keys)
+	}
+
+	// Test case 3: Leading zeros and prefixes
+	if !goflat.NaturalKeySort("a2", "a010") || !goflat.NaturalKeySort("a01", "a1") || !goflat.NaturalKeySort("a", "a0") {
+		t.Errorf("Unexpected natural comparison results")
+	}
+	if goflat.NaturalKeySort("a1", "a1") {
+		t.Errorf("Equal keys must not sort before each other")
+	}
+
+	// Test case 4: The sorted emitters follow KeySort too
+	options = goflat.DefaultOptions()
+	options.KeySort = goflat.NaturalKeySort
+	raw := []byte(`{"item10": 10, "item2": 2}`)
+	out, err := goflat.FlattenJSONToBytes(raw, options)
+	if err != nil || string(out) != `{"item2":2,"item10":10}` {
+		t.Errorf("Unexpected FlattenJSONToBytes output: %s (%v)", out, err)
+	}
+	out, err = goflat.FlattenJSONToBytesLimited(raw, 100, options)
+	if err != nil || string(out) != `{"item2":2,"item10":10}` {
+		t.Errorf("Unexpected FlattenJSONToBytesLimited output: %s (%v)", out, err)
+	}
+	out, err = goflat.FlattenToPrometheus(raw, "", options)
+	if err != nil || string(out) != "item2 2\nitem10 10\n" {
+		t.Errorf("Unexpected FlattenToPrometheus output: %q (%v)", out, err)
+	}
+	out, err = goflat.FlattenToGoLiteral(raw, "fixture", options)
+	if err != nil || strings.Index(string(out), "item2") > strings.Index(string(out), "item10") {
+		t.Errorf("Unexpected FlattenToGoLiteral output: %s (%v)", out, err)
+	}
+	rows, err := goflat.FlattenToRows(raw, options)
+	if err != nil || len(rows) != 2 || rows[0].Key != "item2" {
+		t.Errorf("Unexpected FlattenToRows output: %v (%v)", rows, err)
+	}
+}